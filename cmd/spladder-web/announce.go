@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+// pairingLine formats a challenge as it is announced.
+func pairingLine(c Challenge) string {
+	return fmt.Sprintf("[%d-%d] %d位 %s vs %d位 %s", c.Round, c.Code, c.ChallengerRank, c.Challenger, c.DefenderRank, c.Defender)
+}
+
+// AnnouncePairings posts the pairings of a round to Slack, grouped by division.
+// When the bot can post threads, each division also gets its own message whose thread teams use to coordinate,
+// and the thread is recorded on the challenges of the division.
+func AnnouncePairings(ctx context.Context, tournament *firestore.DocumentRef, round Round) error {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	cfg := settings.SlackConfig()
+
+	docs, err := tournament.Collection("challenges").Where("Round", "==", round).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("round %d has no challenges", round)
+	}
+	byDivision := make(map[Division][]*firestore.DocumentSnapshot)
+	challenges := make(map[string]Challenge)
+	divisions := make([]Division, 0)
+	for _, doc := range docs {
+		var challenge Challenge
+		if err = doc.DataTo(&challenge); err != nil {
+			return err
+		}
+		if _, ok := byDivision[challenge.Division]; !ok {
+			divisions = append(divisions, challenge.Division)
+		}
+		byDivision[challenge.Division] = append(byDivision[challenge.Division], doc)
+		challenges[doc.Ref.ID] = challenge
+	}
+	sort.Slice(divisions, func(i, j int) bool { return divisions[i] < divisions[j] })
+	for _, div := range divisions {
+		sort.Slice(byDivision[div], func(i, j int) bool {
+			return challenges[byDivision[div][i].Ref.ID].Code < challenges[byDivision[div][j].Ref.ID].Code
+		})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!channel> Round %d の対戦カードはこちら！\n", round)
+	for _, div := range divisions {
+		fmt.Fprintf(&b, "\n*Div %s*\n", div.String())
+		for _, doc := range byDivision[div] {
+			b.WriteString(pairingLine(challenges[doc.Ref.ID]) + "\n")
+		}
+	}
+	if _, err := cfg.Post(b.String(), nil, ""); err != nil {
+		return err
+	}
+	if !cfg.CanThread() {
+		return nil
+	}
+
+	for _, div := range divisions {
+		text := fmt.Sprintf("Round %d Div %s の日程調整はこのスレッドでどうぞ！\n", round, div.String())
+		for _, doc := range byDivision[div] {
+			text += pairingLine(challenges[doc.Ref.ID]) + "\n"
+		}
+		ts, err := cfg.Post(text, nil, "")
+		if err != nil {
+			return err
+		}
+		for _, doc := range byDivision[div] {
+			if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "SlackThreadTS", Value: ts}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AnnounceResults posts the outcome of a resolved round to Slack:
+// the winner, neutral and loser of each division, the swaps between divisions and the top of the new ranking.
+func AnnounceResults(ctx context.Context, tournament *firestore.DocumentRef, result *RoundResult) error {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	localTeams, err := LoadTeams(ctx, tournament.Collection("teams"))
+	if err != nil {
+		return err
+	}
+	byID := TeamsByID(localTeams)
+	name := func(id string) string {
+		if team, ok := byID[id]; ok {
+			return team.Name
+		}
+		return id
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!channel> Round %d の結果が確定しました！\n", result.Round)
+	for _, div := range result.Divisions {
+		fmt.Fprintf(&b, "\n*Div %s*\n", div.Division.String())
+		fmt.Fprintf(&b, "1位抜け: %s\n", name(div.Winner))
+		if div.Neutral != "" {
+			fmt.Fprintf(&b, "残留: %s\n", name(div.Neutral))
+		}
+		fmt.Fprintf(&b, "最下位: %s\n", name(div.Loser))
+	}
+	if len(result.Swaps) > 0 {
+		b.WriteString("\n*入れ替え*\n")
+		for _, swap := range result.Swaps {
+			fmt.Fprintf(&b, "%d位 %s ⇔ %d位 %s\n", swap.Rank, name(swap.Down), swap.Rank+1, name(swap.Up))
+		}
+	}
+
+	topN := settings.AnnounceTopN
+	if topN == 0 {
+		topN = 10
+	}
+	fmt.Fprintf(&b, "\n*Round %d ランキング*\n", result.Round+1)
+	for rank := 1; rank <= topN; rank++ {
+		id, ok := result.Ranking[rank]
+		if !ok {
+			break
+		}
+		fmt.Fprintf(&b, "%d位 %s\n", rank, name(id))
+	}
+	_, err = settings.SlackConfig().Post(b.String(), nil, "")
+	return err
+}
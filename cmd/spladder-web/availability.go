@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// slotStep is the granularity of suggested match times.
+const slotStep = 30 * time.Minute
+
+// AvailabilityWindow is a weekly period in which a team can play, e.g. Sat 21:00-23:00.
+type AvailabilityWindow struct {
+	Weekday time.Weekday `firestore:"Weekday"`
+	Start   string       `firestore:"Start"`
+	End     string       `firestore:"End"`
+}
+
+// Availability holds the weekly availability of a team for a round.
+type Availability struct {
+	TeamID  string               `firestore:"TeamID"`
+	Round   Round                `firestore:"Round"`
+	Windows []AvailabilityWindow `firestore:"Windows"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// minutesOfDay converts "21:30" to minutes since midnight.
+func minutesOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// endOfWindow converts the end of a window to minutes since midnight, accepting "24:00" for the end of the day.
+func endOfWindow(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	return minutesOfDay(s)
+}
+
+// ParseAvailability parses windows such as "Sat_21:00-23:00,Sun_22:00-24:00".
+func ParseAvailability(input string) ([]AvailabilityWindow, error) {
+	windows := make([]AvailabilityWindow, 0)
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, hours, ok := strings.Cut(part, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid window %q", part)
+		}
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", day)
+		}
+		start, end, ok := strings.Cut(hours, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid window %q", part)
+		}
+		s, err := minutesOfDay(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %s", part, err)
+		}
+		e, err := endOfWindow(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %s", part, err)
+		}
+		if e <= s {
+			return nil, fmt.Errorf("window %q ends before it starts", part)
+		}
+		windows = append(windows, AvailabilityWindow{Weekday: weekday, Start: start, End: end})
+	}
+	return windows, nil
+}
+
+// Covers reports whether the availability covers the whole period from t for d.
+// The weekday and time of day are taken in t's location.
+func (a Availability) Covers(t time.Time, d time.Duration) bool {
+	from := t.Hour()*60 + t.Minute()
+	to := from + int(d.Minutes())
+	for _, w := range a.Windows {
+		if w.Weekday != t.Weekday() {
+			continue
+		}
+		s, err := minutesOfDay(w.Start)
+		if err != nil {
+			continue
+		}
+		e, err := endOfWindow(w.End)
+		if err != nil {
+			continue
+		}
+		if s <= from && to <= e {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAvailability stores the availability of a team for a round.
+func SetAvailability(ctx context.Context, tournament *firestore.DocumentRef, a Availability) error {
+	_, err := tournament.Collection("teams").Doc(a.TeamID).Collection("availability").Doc(a.Round.String()).Set(ctx, a)
+	return err
+}
+
+// LoadAvailability reads the availability of a team for a round. A team without availability has no windows.
+func LoadAvailability(ctx context.Context, tournament *firestore.DocumentRef, teamID string, round Round) (Availability, error) {
+	a := Availability{TeamID: teamID, Round: round}
+	doc, err := tournament.Collection("teams").Doc(teamID).Collection("availability").Doc(round.String()).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return a, nil
+	}
+	if err != nil {
+		return a, err
+	}
+	err = doc.DataTo(&a)
+	return a, err
+}
+
+// booking is a period in which a team already has a match.
+type booking struct {
+	start, end time.Time
+}
+
+func overlaps(bookings []booking, start, end time.Time) bool {
+	for _, b := range bookings {
+		if start.Before(b.end) && b.start.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestMatchTimes proposes a mutually available time for each unscheduled challenge of the round.
+// Availability windows are read in the time zone of the tournament.
+// A team is never given two matches that overlap, including matches that are already scheduled.
+func SuggestMatchTimes(ctx context.Context, tournament *firestore.DocumentRef, round Round) error {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	duration := time.Duration(settings.MatchMinutes) * time.Minute
+	if duration == 0 {
+		duration = time.Hour
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return err
+	}
+	w, err := LoadRoundWindow(ctx, tournament, round)
+	if err != nil {
+		return err
+	}
+	if w.Start.IsZero() || w.End.IsZero() {
+		return fmt.Errorf("round %d has no window", round)
+	}
+	first := w.Start.Truncate(slotStep)
+	if now := time.Now(); first.Before(now) {
+		first = now.Truncate(slotStep).Add(slotStep)
+	}
+
+	docs, err := tournament.Collection("challenges").Where("Round", "==", round).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	challenges := make(map[string]Challenge)
+	keys := make([]string, 0, len(docs))
+	booked := make(map[string][]booking)
+	for _, doc := range docs {
+		var challenge Challenge
+		if err = doc.DataTo(&challenge); err != nil {
+			return err
+		}
+		challenges[doc.Ref.ID] = challenge
+		keys = append(keys, doc.Ref.ID)
+		for _, t := range []time.Time{challenge.Date, challenge.ProposedDate} {
+			if t.IsZero() {
+				continue
+			}
+			b := booking{start: t, end: t.Add(duration)}
+			booked[challenge.ChallengerID] = append(booked[challenge.ChallengerID], b)
+			booked[challenge.DefenderID] = append(booked[challenge.DefenderID], b)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return challenges[keys[i]].Code < challenges[keys[j]].Code
+	})
+
+	availability := make(map[string]Availability)
+	for _, key := range keys {
+		challenge := challenges[key]
+		if !challenge.Date.IsZero() || !challenge.ProposedDate.IsZero() {
+			continue
+		}
+		for _, id := range []string{challenge.ChallengerID, challenge.DefenderID} {
+			if _, ok := availability[id]; ok {
+				continue
+			}
+			a, err := LoadAvailability(ctx, tournament, id, round)
+			if err != nil {
+				return err
+			}
+			availability[id] = a
+		}
+
+		var slot time.Time
+		for t := first; !t.Add(duration).After(w.End); t = t.Add(slotStep) {
+			local := t.In(loc)
+			if !availability[challenge.ChallengerID].Covers(local, duration) || !availability[challenge.DefenderID].Covers(local, duration) {
+				continue
+			}
+			if overlaps(booked[challenge.ChallengerID], t, t.Add(duration)) || overlaps(booked[challenge.DefenderID], t, t.Add(duration)) {
+				continue
+			}
+			slot = t
+			break
+		}
+		if slot.IsZero() {
+			fmt.Printf("[%s] %s vs %s: no common time found\n", key, challenge.Challenger, challenge.Defender)
+			continue
+		}
+
+		b := booking{start: slot, end: slot.Add(duration)}
+		booked[challenge.ChallengerID] = append(booked[challenge.ChallengerID], b)
+		booked[challenge.DefenderID] = append(booked[challenge.DefenderID], b)
+		_, err = tournament.Collection("challenges").Doc(key).Update(ctx, []firestore.Update{
+			{Path: "ProposedDate", Value: slot},
+			{Path: "ProposedBy", Value: "scheduler"},
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[%s] %s vs %s: %s\n", key, challenge.Challenger, challenge.Defender, slot.In(loc).Format(dateLayout))
+	}
+	return nil
+}
+
+// InputAvailability stores team availability for a round based on user input.
+func InputAvailability(ctx context.Context, tournament *firestore.DocumentRef, round Round) error {
+	localTeams, err := LoadTeams(ctx, tournament.Collection("teams"))
+	if err != nil {
+		return err
+	}
+	byName := TeamsByName(localTeams)
+	for {
+		var input string
+		fmt.Println("Enter the team name, blank to finish:")
+		name := readLine()
+		if name == "" {
+			return nil
+		}
+		team, ok := byName[name]
+		if !ok {
+			fmt.Printf("Team %q is not registered.\n", name)
+			continue
+		}
+		fmt.Println("Enter the weekly availability (e.g. Sat_21:00-23:00,Sun_22:00-24:00):")
+		fmt.Scanln(&input)
+		windows, err := ParseAvailability(input)
+		if err != nil {
+			fmt.Println("Invalid availability:", err)
+			continue
+		}
+		err = SetAvailability(ctx, tournament, Availability{TeamID: team.ID, Round: round, Windows: windows})
+		if err != nil {
+			return err
+		}
+		fmt.Println("Written to firebase.")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAvailability(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []AvailabilityWindow
+	}{
+		{"", []AvailabilityWindow{}},
+		{"Sat_21:00-23:00", []AvailabilityWindow{{Weekday: time.Saturday, Start: "21:00", End: "23:00"}}},
+		{"sat_21:00-23:00, SUN_22:00-24:00,", []AvailabilityWindow{
+			{Weekday: time.Saturday, Start: "21:00", End: "23:00"},
+			{Weekday: time.Sunday, Start: "22:00", End: "24:00"},
+		}},
+		{"Mon_00:00-24:00", []AvailabilityWindow{{Weekday: time.Monday, Start: "00:00", End: "24:00"}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseAvailability(tt.input)
+		if err != nil {
+			t.Errorf("ParseAvailability(%q): %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAvailability(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{
+		"Sat 21:00-23:00",
+		"Saturday_21:00-23:00",
+		"Sat_21:00",
+		"Sat_21-23",
+		"Sat_21:00-25:00",
+		"Sat_24:00-24:00",
+		"Sat_23:00-21:00",
+		"Sat_21:00-21:00",
+		"Sat_21:00-23:00,Sun",
+	} {
+		if got, err := ParseAvailability(input); err == nil {
+			t.Errorf("ParseAvailability(%q) = %v, want an error", input, got)
+		}
+	}
+}
+
+func TestCovers(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	a := Availability{Windows: []AvailabilityWindow{
+		{Weekday: time.Saturday, Start: "21:00", End: "23:00"},
+		{Weekday: time.Sunday, Start: "22:00", End: "24:00"},
+	}}
+	// 2022-05-14 is a Saturday.
+	tests := []struct {
+		start time.Time
+		d     time.Duration
+		want  bool
+	}{
+		{time.Date(2022, 5, 14, 21, 0, 0, 0, jst), time.Hour, true},
+		{time.Date(2022, 5, 14, 22, 0, 0, 0, jst), time.Hour, true},
+		{time.Date(2022, 5, 14, 21, 0, 0, 0, jst), 2 * time.Hour, true},
+		{time.Date(2022, 5, 14, 22, 30, 0, 0, jst), time.Hour, false},
+		{time.Date(2022, 5, 14, 20, 30, 0, 0, jst), time.Hour, false},
+		{time.Date(2022, 5, 15, 23, 0, 0, 0, jst), time.Hour, true},
+		{time.Date(2022, 5, 15, 23, 30, 0, 0, jst), time.Hour, false},
+		{time.Date(2022, 5, 13, 21, 0, 0, 0, jst), time.Hour, false},
+		// The weekday and time of day are those of the given location: 12:00 UTC is 21:00 in Japan.
+		{time.Date(2022, 5, 14, 12, 0, 0, 0, time.UTC), time.Hour, false},
+		{time.Date(2022, 5, 14, 12, 0, 0, 0, time.UTC).In(jst), time.Hour, true},
+	}
+	for _, tt := range tests {
+		if got := a.Covers(tt.start, tt.d); got != tt.want {
+			t.Errorf("Covers(%s, %s) = %v, want %v", tt.start, tt.d, got, tt.want)
+		}
+	}
+	if (Availability{}).Covers(time.Date(2022, 5, 14, 21, 0, 0, 0, jst), time.Hour) {
+		t.Error("an empty availability covers a match")
+	}
+}
@@ -2,14 +2,14 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
 	"fmt"
 	"log"
-	"os"
 	"sort"
 	"strconv"
+	"time"
 
 	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
 
 	firebase "firebase.google.com/go"
 	"google.golang.org/api/iterator"
@@ -17,111 +17,21 @@ import (
 )
 
 // Round represents a round in the tournament.
-type Round int
-
-func (r Round) String() string {
-	return strconv.Itoa(int(r))
-}
+type Round = ladder.Round
 
 // Division represents a division in the tournament.
-type Division int
-
-// This iota represents a list of divisions.
-const (
-	X Division = iota
-	SPlusUpper
-	SPlusLower
-	SUpper
-	SLower
-	APlusUpper
-	APlusLower
-	AUpper
-	ALower
-	AMinusUpper
-	AMinusLower
-	BPlusUpper
-	BPlusLower
-	BUpper
-	BLower
-	BMinusUpper
-	BMinusLower
-	CPlusUpper
-	CPlusLower
-	CUpper
-	CLower
-	CMinusUpper
-	CMinusLower
-)
+type Division = ladder.Division
 
-func (div Division) String() string {
-	switch div {
-	case X:
-		return "X"
-	case SPlusUpper:
-		return "S+ Upper"
-	case SPlusLower:
-		return "S+ Lower"
-	case SUpper:
-		return "S Upper"
-	case SLower:
-		return "S Lower"
-	case APlusUpper:
-		return "A+ Upper"
-	case APlusLower:
-		return "A+ Lower"
-	case AUpper:
-		return "A Upper"
-	case ALower:
-		return "A Lower"
-	case AMinusUpper:
-		return "A- Upper"
-	case AMinusLower:
-		return "A- Lower"
-	case BPlusUpper:
-		return "B+ Upper"
-	case BPlusLower:
-		return "B+ Lower"
-	case BUpper:
-		return "B Upper"
-	case BLower:
-		return "B Lower"
-	case BMinusUpper:
-		return "B- Upper"
-	case BMinusLower:
-		return "B- Lower"
-	case CPlusUpper:
-		return "C+ Upper"
-	case CPlusLower:
-		return "C+ Lower"
-	case CUpper:
-		return "C Upper"
-	case CLower:
-		return "C Lower"
-	case CMinusUpper:
-		return "C- Upper"
-	case CMinusLower:
-		return "C- Lower"
-	default:
-		return "Unknown"
-	}
-}
+// X is the top division.
+const X = ladder.X
 
 // Challenge holds data for a challenge.
-type Challenge struct {
-	Round           Round    `firestore:"Round"`
-	Code            int      `firestore:"Code"`
-	Challenger      string   `firestore:"Challenger"`
-	ChallengerRank  int      `firestore:"ChallengerRank"`
-	ChallengerScore int      `firestore:"ChallengerScore"`
-	Defender        string   `firestore:"Defender"`
-	DefenderRank    int      `firestore:"DefenderRank"`
-	DefenderScore   int      `firestore:"DefenderScore"`
-	Division        Division `firestore:"Division"`
-}
+type Challenge = ladder.Challenge
 
 // TeamMetadata holds metrics for a team per round.
 type TeamMetadata struct {
 	Team          string   `firestore:"Team"`
+	TeamID        string   `firestore:"TeamID"`
 	Division      Division `firestore:"Division"`
 	Round         Round    `firestore:"Round"`
 	Rank          int      `firestore:"Rank"`
@@ -132,6 +42,7 @@ type TeamMetadata struct {
 }
 
 // DivisionMetadata holds metrics for a division per round.
+// Winner, Loser and Neutral hold team IDs.
 type DivisionMetadata struct {
 	Division Division `firestore:"Division"`
 	Winner   string   `firestore:"Winner"`
@@ -140,84 +51,131 @@ type DivisionMetadata struct {
 }
 
 // InitTeams loads a local csv file given by path and uploads it to Firestore.
-func InitTeams(ctx context.Context, teams *firestore.CollectionRef, path string) (int, error) {
-	teamCount := 0
-	// Load team CSV file.
-	csvfile, err := os.Open(path)
+// When teams are already registered, the changes are shown and only applied after confirmation.
+// Players missing from the player registry are registered along with the roster, and no players may join once the roster is locked.
+// Removing a team that holds a rank in the latest ranking needs its own confirmation; otherwise the team is kept.
+func InitTeams(ctx context.Context, tournament *firestore.DocumentRef, players *firestore.CollectionRef, path string) (int, error) {
+	teams := tournament.Collection("teams")
+	settings, err := LoadTournament(ctx, tournament)
 	if err != nil {
-		return teamCount, err
+		return 0, err
 	}
-
-	teamsDoc := make([]map[string]string, 0)
-
-	r := csv.NewReader(csvfile)
-	localTeams, err := r.ReadAll()
+	localTeams, err := ReadTeamsCSV(path)
 	if err != nil {
-		return teamCount, err
+		return 0, err
 	}
-	for _, team := range localTeams {
-		doc := make(map[string]string)
-		for column, name := range team {
-			switch column {
-			case 0:
-				doc["name"] = name
-			case 1:
-				doc["player1"] = name
-			case 2:
-				doc["player2"] = name
-			case 3:
-				doc["player3"] = name
-			case 4:
-				doc["player4"] = name
-			case 5:
-				if name != "" {
-					doc["player5"] = name
+	registry, err := LoadPlayers(ctx, players)
+	if err != nil {
+		return 0, err
+	}
+	if err := CheckRosterRules(localTeams, registry); err != nil {
+		return 0, err
+	}
+	localTeams, newPlayers := RegisterPlayers(players, registry, localTeams)
+	existing, err := LoadTeams(ctx, teams)
+	if err != nil {
+		return 0, err
+	}
+
+	diff := DiffTeams(existing, localTeams)
+	diff.NewPlayers = newPlayers
+	if diff.Empty() {
+		fmt.Println("No roster changes.")
+		return len(localTeams), nil
+	}
+	fmt.Println("Roster changes:")
+	fmt.Print(diff)
+	if settings.RosterLocked(time.Now()) && diff.HasNewPlayers() {
+		return len(existing), fmt.Errorf("the roster was locked on %s", settings.RosterLockDate.Format("2006-01-02 15:04"))
+	}
+
+	var s string
+	if len(diff.Removed) > 0 {
+		ranks, round, err := LatestRanking(ctx, tournament)
+		if err != nil {
+			return len(existing), err
+		}
+		removed := make([]Team, 0, len(diff.Removed))
+		for _, t := range diff.Removed {
+			if rank, ok := ranks[t.ID]; ok {
+				fmt.Printf("%s is ranked %d in round %s. Removing it deletes its metrics and leaves its rank empty. Remove anyway? y/n\n",
+					t.Name, rank, round)
+				s = ""
+				fmt.Scanln(&s)
+				if s != "y" {
+					fmt.Println("Keeping", t.Name)
+					continue
 				}
 			}
+			removed = append(removed, t)
+		}
+		diff.Removed = removed
+		if diff.Empty() {
+			fmt.Println("No roster changes.")
+			return len(existing), nil
 		}
-		teamCount++
-		teamsDoc = append(teamsDoc, doc)
 	}
 
-	for _, doc := range teamsDoc {
-		name := doc["name"]
-		_, err := teams.Doc(name).Set(ctx, doc)
-		if err != nil {
-			return teamCount, err
-		}
+	s = ""
+	fmt.Println("Apply? y/n")
+	fmt.Scanln(&s)
+	if s != "y" {
+		return len(existing), nil
+	}
+	if err := ApplyRosterDiff(ctx, players, teams, diff); err != nil {
+		return len(existing), err
 	}
-	return teamCount, nil
+	return len(localTeams), nil
 }
 
 // InitRanking initializes the ranking based on user input.
 func InitRanking(ctx context.Context, teams *firestore.CollectionRef, ranking *firestore.DocumentRef) error {
-	// Read from the teams document.
-	var rank string
+	localTeams, err := LoadTeams(ctx, teams)
+	if err != nil {
+		return err
+	}
+	byID := TeamsByID(localTeams)
 
-	iter := teams.Documents(ctx)
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
+	localRank := make(map[int]string)
+	for _, team := range localTeams {
+		for {
+			var rank int
+			fmt.Println("Enter the ranking for: ", team.Name)
+			fmt.Scanln(&rank)
+			if rank < 1 || rank > len(localTeams) {
+				fmt.Printf("Rank must be between 1 and %d.\n", len(localTeams))
+				continue
+			}
+			if other, ok := localRank[rank]; ok {
+				fmt.Printf("Rank %d is already held by %s.\n", rank, byID[other].Name)
+				continue
+			}
+			localRank[rank] = team.ID
 			break
 		}
-		if err != nil {
-			return err
-		}
-		data := doc.Data()
-		fmt.Println("Enter the ranking for: ", data["name"])
-		fmt.Scanln(&rank)
-		_, err = ranking.Set(ctx, map[string]interface{}{
-			rank: data["name"],
-		}, firestore.MergeAll)
-		if err != nil {
-			return err
-		}
 	}
-	return nil
+	if err := ValidateRanking(localRank, localTeams); err != nil {
+		return err
+	}
+	return UploadRanking(ctx, ranking, localRank)
 }
 
-// InputScores uploads challenge scores based on user input.
-func InputScores(ctx context.Context, challenges firestore.Query) {
+// InputScores uploads challenge scores and lineups based on user input.
+func InputScores(ctx context.Context, tournament *firestore.DocumentRef, players *firestore.CollectionRef, challenges firestore.Query) {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		log.Fatal(err)
+	}
+	localTeams, err := LoadTeams(ctx, tournament.Collection("teams"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	byID := TeamsByID(localTeams)
+	registry, err := LoadPlayers(ctx, players)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Read challenges for the current round and input the scores.
 	iter := challenges.Documents(ctx)
 	for {
@@ -239,32 +197,111 @@ func InputScores(ctx context.Context, challenges firestore.Query) {
 				challenge.Defender, challenge.DefenderRank)
 			fmt.Printf("Input score for challenger %s: ", challenge.Challenger)
 			var cs, ds int
-			fmt.Scanf("%d", &cs)
+			fmt.Scanln(&cs)
 			fmt.Printf("Input score for defender %s: ", challenge.Defender)
-			fmt.Scanf("%d", &ds)
-			if cs+ds >= 4 && cs+ds <= 7 && (cs == 4 || ds == 4) {
-				challenge.ChallengerScore = cs
-				challenge.DefenderScore = ds
-				_, err = doc.Ref.Set(ctx, challenge)
-				if err != nil {
-					log.Printf("Error occurred writing to Firestore: %s", err)
+			fmt.Scanln(&ds)
+			if !settings.ValidScore(cs, ds) {
+				if !askRetry("Invalid score.") {
+					break
 				}
-				fmt.Println("Written to firebase.")
-				break
-			} else {
-				var s string
-				fmt.Println("Invalid score. Try again? y/n")
-				fmt.Scanln(&s)
-				if s != "y" {
+				continue
+			}
+
+			challengerLineup, defenderLineup, err := inputLineups(ctx, tournament, settings, localTeams, registry,
+				doc.Ref.ID, byID[challenge.ChallengerID], byID[challenge.DefenderID])
+			if err != nil {
+				if !askRetry(fmt.Sprintf("Invalid lineup: %s.", err)) {
 					break
 				}
+				continue
+			}
+
+			challenge.ChallengerScore = cs
+			challenge.DefenderScore = ds
+			challenge.ChallengerLineup = challengerLineup
+			challenge.DefenderLineup = defenderLineup
+			updates := []firestore.Update{
+				{Path: "ChallengerScore", Value: challenge.ChallengerScore},
+				{Path: "DefenderScore", Value: challenge.DefenderScore},
+				{Path: "ChallengerLineup", Value: challenge.ChallengerLineup},
+				{Path: "DefenderLineup", Value: challenge.DefenderLineup},
+				{Path: "Flag", Value: firestore.Delete},
+				{Path: "Forfeit", Value: firestore.Delete},
+			}
+			// Scores entered by an organiser settle any report made through Slack.
+			if challenge.ReportStatus != "" {
+				updates = append(updates,
+					firestore.Update{Path: "ReportStatus", Value: ladder.ReportRecorded},
+					firestore.Update{Path: "ConfirmedBy", Value: "organiser"},
+					firestore.Update{Path: "ConfirmedAt", Value: time.Now()})
+			}
+			_, err = doc.Ref.Update(ctx, updates)
+			if err != nil {
+				log.Printf("Error occurred writing to Firestore: %s", err)
+			}
+			fmt.Println("Written to firebase.")
+			break
+		}
+	}
+}
+
+func askRetry(reason string) bool {
+	var s string
+	fmt.Println(reason, "Try again? y/n")
+	fmt.Scanln(&s)
+	return s == "y"
+}
+
+// inputLineups asks for the players each team fielded. Both lineups are required, as scores are only
+// recorded along with them.
+func inputLineups(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, localTeams []Team,
+	registry map[string]Player, challengeID string, challenger, defender Team) ([]string, []string, error) {
+	lineups := make([][]string, 0, 2)
+	for _, team := range []Team{challenger, defender} {
+		fmt.Printf("Input lineup for %s (comma separated handles): ", team.Name)
+		lineup, err := ParseLineup(readLine(), registry)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := ValidateLineup(ctx, tournament, settings, localTeams, challengeID, team, lineup); err != nil {
+			return nil, nil, err
+		}
+		lineups = append(lineups, lineup)
+	}
+	for _, id := range lineups[0] {
+		for _, other := range lineups[1] {
+			if id == other {
+				return nil, nil, fmt.Errorf("a player cannot play for both teams")
 			}
 		}
 	}
+	return lineups[0], lineups[1], nil
+}
+
+// RankSwap records a division loser swapping places with the winner of the division below.
+// Down and Up hold team IDs.
+type RankSwap struct {
+	Rank int
+	Down string
+	Up   string
+}
+
+// RoundResult summarises how a round was resolved.
+type RoundResult struct {
+	Round     Round
+	Divisions []DivisionMetadata
+	Swaps     []RankSwap
+	// Ranking maps each rank of the next round to a team ID.
+	Ranking map[int]string
 }
 
 // GenerateRanking generates ranking for the current round based on the last challenge scores.
-func GenerateRanking(ctx context.Context, tournament *firestore.DocumentRef, challenges firestore.Query) error {
+func GenerateRanking(ctx context.Context, tournament *firestore.DocumentRef, challenges firestore.Query) (*RoundResult, error) {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return nil, err
+	}
+	var result RoundResult
 	teamMetrics := make(map[string]*TeamMetadata)
 	divisionMetrics := make(map[Division]*DivisionMetadata)
 	divisionToTeam := make(map[Division][]string)
@@ -281,65 +318,75 @@ func GenerateRanking(ctx context.Context, tournament *firestore.DocumentRef, cha
 			break
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 		var challenge Challenge
 		if err = doc.DataTo(&challenge); err != nil {
-			return err
+			return nil, err
 		}
 		// Populate challenger related metrics
 		nextRound = challenge.Round + 1
 		var challenger, defender *TeamMetadata
-		if val, ok := teamMetrics[challenge.Challenger]; ok {
+		if val, ok := teamMetrics[challenge.ChallengerID]; ok {
 			challenger = val
 		} else {
 			var c TeamMetadata
 			challenger = &c
 			challenger.Team = challenge.Challenger
+			challenger.TeamID = challenge.ChallengerID
 			challenger.Round = challenge.Round
 			challenger.Division = challenge.Division
 			challenger.Rank = challenge.ChallengerRank
-			teamMetrics[challenge.Challenger] = challenger
-			divisionToTeam[challenger.Division] = append(divisionToTeam[challenger.Division], challenger.Team)
+			teamMetrics[challenge.ChallengerID] = challenger
+			divisionToTeam[challenger.Division] = append(divisionToTeam[challenger.Division], challenger.TeamID)
 		}
-		if val, ok := teamMetrics[challenge.Defender]; ok {
+		if val, ok := teamMetrics[challenge.DefenderID]; ok {
 			defender = val
 		} else {
 			var d TeamMetadata
 			defender = &d
 			defender.Team = challenge.Defender
+			defender.TeamID = challenge.DefenderID
 			defender.Round = challenge.Round
 			defender.Division = challenge.Division
 			defender.Rank = challenge.DefenderRank
-			teamMetrics[challenge.Defender] = defender
-			divisionToTeam[defender.Division] = append(divisionToTeam[defender.Division], defender.Team)
+			teamMetrics[challenge.DefenderID] = defender
+			divisionToTeam[defender.Division] = append(divisionToTeam[defender.Division], defender.TeamID)
 		}
 
-		if challenge.ChallengerScore == 4 {
+		if challenge.Unrecorded() {
+			return nil, fmt.Errorf("the reported result of %d-%d (%s vs %s) is %s and has not been recorded with lineups",
+				challenge.Round, challenge.Code, challenger.Team, defender.Team, challenge.ReportStatus)
+		}
+		if challenge.Forfeit == "double" {
+			fmt.Printf("%s and %s both forfeited.\n", challenger.Team, defender.Team)
+			challenger.NumLosses++
+			defender.NumLosses++
+		} else if challenge.ChallengerScore == settings.Wins() {
 			fmt.Printf("%s won. %s lost.\n", challenger.Team, defender.Team)
 			challenger.NumWins++
 			defender.NumLosses++
-		} else if challenge.DefenderScore == 4 {
+		} else if challenge.DefenderScore == settings.Wins() {
 			fmt.Printf("%s won. %s lost.\n", defender.Team, challenger.Team)
 			defender.NumWins++
 			challenger.NumLosses++
 		} else {
-			return fmt.Errorf("Invalid scores detected for %d-%d: %s vs %s", challenge.Round, challenge.Code, challenger.Team, defender.Team)
+			return nil, fmt.Errorf("Invalid scores detected for %d-%d: %s vs %s", challenge.Round, challenge.Code, challenger.Team, defender.Team)
 		}
 		challenger.NumSetsGained += challenge.ChallengerScore
 		challenger.NumSetsLost += challenge.DefenderScore
 		defender.NumSetsGained += challenge.DefenderScore
 		defender.NumSetsLost += challenge.ChallengerScore
 
-		_, err = tournament.Collection("teams").Doc(challenger.Team).Collection("metrics").Doc(challenge.Round.String()).Set(ctx, challenger)
+		_, err = tournament.Collection("teams").Doc(challenger.TeamID).Collection("metrics").Doc(challenge.Round.String()).Set(ctx, challenger)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fmt.Println("Uploading to firestore successful:", challenger.Team)
 		fmt.Println(challenger)
-		_, err = tournament.Collection("teams").Doc(defender.Team).Collection("metrics").Doc(challenge.Round.String()).Set(ctx, defender)
+		_, err = tournament.Collection("teams").Doc(defender.TeamID).Collection("metrics").Doc(challenge.Round.String()).Set(ctx, defender)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fmt.Println("Uploading to firestore successful:", defender.Team)
 		fmt.Println(defender)
@@ -373,28 +420,32 @@ func GenerateRanking(ctx context.Context, tournament *firestore.DocumentRef, cha
 			return t1.Rank > t2.Rank
 		})
 		if len(teamsInDiv) < 3 {
-			divMetadata.Winner = teams[0].Team
-			divMetadata.Loser = teams[1].Team
+			divMetadata.Winner = teams[0].TeamID
+			divMetadata.Loser = teams[1].TeamID
+			fmt.Printf("Division %s Winner: %s Loser: %s\n", div.String(), teams[0].Team, teams[1].Team)
 		} else {
-			divMetadata.Winner = teams[0].Team
-			divMetadata.Neutral = teams[1].Team
-			divMetadata.Loser = teams[2].Team
+			divMetadata.Winner = teams[0].TeamID
+			divMetadata.Neutral = teams[1].TeamID
+			divMetadata.Loser = teams[2].TeamID
+			fmt.Printf("Division %s Winner: %s Loser: %s Neutral: %s\n", div.String(), teams[0].Team, teams[2].Team, teams[1].Team)
 		}
 
-		fmt.Printf("Division %s Winner: %s Loser: %s Neutral: %s\n", div.String(), divMetadata.Winner, divMetadata.Loser, divMetadata.Neutral)
 		localRank = append(localRank, divMetadata.Winner)
 		if divMetadata.Neutral != "" {
 			localRank = append(localRank, divMetadata.Neutral)
 		}
 		localRank = append(localRank, divMetadata.Loser)
 		divisionMetrics[div] = &divMetadata
+		result.Divisions = append(result.Divisions, divMetadata)
 	}
 
 	// Swap ranking based on loser information.
 	for div := X; int(div) < len(divisionMetrics)-1; div++ {
 		for rank, team := range localRank {
 			if team == divisionMetrics[div].Loser {
-				fmt.Printf("Swapping %s at rank %d with %s at rank %d\n", team, rank, localRank[rank+1], rank+1)
+				fmt.Printf("Swapping %s at rank %d with %s at rank %d\n", teamMetrics[team].Team, rank,
+					teamMetrics[localRank[rank+1]].Team, rank+1)
+				result.Swaps = append(result.Swaps, RankSwap{Rank: rank, Down: team, Up: localRank[rank+1]})
 				localRank[rank], localRank[rank+1] = localRank[rank+1], localRank[rank]
 				break
 			}
@@ -402,22 +453,37 @@ func GenerateRanking(ctx context.Context, tournament *firestore.DocumentRef, cha
 	}
 
 	// Create a map to upload to Firestore.
+	result.Ranking = make(map[int]string)
 	for rank, team := range localRank {
 		if rank > 0 {
 			rankToUpload[strconv.Itoa(rank)] = team
+			result.Ranking[rank] = team
 		}
 	}
 
 	// Upload new ranking to Firestore.
-	_, err := ranking.Doc(nextRound.String()).Set(ctx, rankToUpload)
+	_, err = ranking.Doc(nextRound.String()).Set(ctx, rankToUpload)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	result.Round = nextRound - 1
+	return &result, nil
 }
 
 // CreateChallenges generate challenges based on the current team ranking and uploads it to Firestore.
 func CreateChallenges(ctx context.Context, tournament *firestore.DocumentRef, round Round) {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		log.Fatalln("Error reading tournament settings from Firestore: ", err)
+	}
+	label := settings.Label(tournament.ID)
+
+	localTeams, err := LoadTeams(ctx, tournament.Collection("teams"))
+	if err != nil {
+		log.Fatalln("Error reading teams from Firestore: ", err)
+	}
+	names := TeamsByID(localTeams)
+
 	// Get ranking from current round.
 	teams := make(map[int]string)
 	rankingdsnap, err := tournament.Collection("ranking").Doc(round.String()).Get(ctx)
@@ -436,8 +502,8 @@ func CreateChallenges(ctx context.Context, tournament *firestore.DocumentRef, ro
 
 	// Figure out divisions. Assign map[string][]int which maps divisions to ranks.
 	// Division have the followings names:
-	// X, S+ Upper, S+ Lower, S Upper, S Middle, S Lower, A+ Upper, A+ Middle, A+ Lower,
-	// A Upper, A Middle, A Lower, B Upper, B Middle, B Lower.
+	// X, S+ Upper, S+ Lower, S Upper, S Lower, A+ Upper, A+ Lower, A Upper, A Lower, ...
+	// as listed in ladder.Division.
 
 	divisionToTeam := make(map[Division][]int)
 
@@ -469,15 +535,15 @@ func CreateChallenges(ctx context.Context, tournament *firestore.DocumentRef, ro
 		fmt.Println(division, divTeam)
 		numTeams := len(divTeam)
 		for key, teamRank := range divTeam {
-			fmt.Println(division, key, teamRank, teams[teamRank])
+			fmt.Println(division, key, teamRank, names[teams[teamRank]].Name)
 			switch key {
 			case 0:
 				var challenge Challenge
 				challenge.Division = division
 				challenge.Round = round
-				challenge.Defender = teams[teamRank]
+				challenge.DefenderID = teams[teamRank]
 				challenge.DefenderRank = teamRank
-				challenge.Challenger = teams[teamRank+1]
+				challenge.ChallengerID = teams[teamRank+1]
 				challenge.ChallengerRank = teamRank + 1
 				challenge.Code = code
 				challenges[strconv.Itoa(challenge.Code)] = challenge
@@ -486,9 +552,9 @@ func CreateChallenges(ctx context.Context, tournament *firestore.DocumentRef, ro
 				if numTeams == 3 {
 					challenge.Division = division
 					challenge.Round = round
-					challenge.Defender = teams[teamRank]
+					challenge.DefenderID = teams[teamRank]
 					challenge.DefenderRank = teamRank
-					challenge.Challenger = teams[teamRank+2]
+					challenge.ChallengerID = teams[teamRank+2]
 					challenge.ChallengerRank = teamRank + 2
 					challenge.Code = code
 					challenges[strconv.Itoa(challenge.Code)] = challenge
@@ -499,9 +565,9 @@ func CreateChallenges(ctx context.Context, tournament *firestore.DocumentRef, ro
 					var challenge Challenge
 					challenge.Division = division
 					challenge.Round = round
-					challenge.Defender = teams[teamRank]
+					challenge.DefenderID = teams[teamRank]
 					challenge.DefenderRank = teamRank
-					challenge.Challenger = teams[teamRank+1]
+					challenge.ChallengerID = teams[teamRank+1]
 					challenge.ChallengerRank = teamRank + 1
 					challenge.Code = code
 					challenges[strconv.Itoa(challenge.Code)] = challenge
@@ -511,10 +577,17 @@ func CreateChallenges(ctx context.Context, tournament *firestore.DocumentRef, ro
 		}
 	}
 
+	// Fill in the team names for display.
+	for matchCode, challenge := range challenges {
+		challenge.Challenger = names[challenge.ChallengerID].Name
+		challenge.Defender = names[challenge.DefenderID].Name
+		challenges[matchCode] = challenge
+	}
+
 	for i := 1; i < len(teams)+1; i++ {
 		challenge := challenges[strconv.Itoa(i)]
 		code := i
-		fmt.Printf("Spladder#8 Div %s [%d-%d] %d位 %s vs %d位 %s \n", challenge.Division.String(), challenge.Round, code,
+		fmt.Printf("%s Div %s [%d-%d] %d位 %s vs %d位 %s \n", label, challenge.Division.String(), challenge.Round, code,
 			challenge.ChallengerRank, challenge.Challenger, challenge.DefenderRank, challenge.Defender)
 	}
 
@@ -537,19 +610,22 @@ func AddNewTeam(ctx context.Context, tournament *firestore.DocumentRef, currentR
 	rankToUpload := make(map[string]string)
 	var s string
 	var j int
-	doc, err := ranking.Get(ctx)
+	localTeams, err := LoadTeams(ctx, tournament.Collection("teams"))
 	if err != nil {
 		return err
 	}
-	data := doc.Data()
+	byID := TeamsByID(localTeams)
+	byName := TeamsByName(localTeams)
+	doc, err := ranking.Get(ctx)
 	if err != nil {
 		return err
 	}
+	data := doc.Data()
 	fmt.Println(currentRound)
 	fmt.Println("Current ranking:")
 	for i := 1; i < len(data)+1; i++ {
 		oldRanking[i] = fmt.Sprintf("%v", data[strconv.Itoa(i)])
-		fmt.Println(i, oldRanking[i])
+		fmt.Println(i, byID[oldRanking[i]].Name)
 	}
 	fmt.Println("Type ranking to insert:")
 	fmt.Scanln(&j)
@@ -559,14 +635,18 @@ func AddNewTeam(ctx context.Context, tournament *firestore.DocumentRef, currentR
 		} else if i == j {
 			fmt.Println("Type team name:")
 			fmt.Scanln(&s)
-			newRanking[i] = s
+			team, ok := byName[s]
+			if !ok {
+				return fmt.Errorf("team %q is not registered", s)
+			}
+			newRanking[i] = team.ID
 		} else if i > j {
 			newRanking[i] = oldRanking[i-1]
 		}
 	}
 	fmt.Println("New ranking:")
 	for i := 1; i < len(newRanking)+1; i++ {
-		fmt.Println(i, newRanking[i])
+		fmt.Println(i, byID[newRanking[i]].Name)
 	}
 	// Create a map to upload to Firestore.
 	for rank, team := range newRanking {
@@ -586,6 +666,19 @@ func AddNewTeam(ctx context.Context, tournament *firestore.DocumentRef, currentR
 	return nil
 }
 
+// announcePairings asks whether to announce the pairings of a newly opened round.
+func announcePairings(ctx context.Context, tournament *firestore.DocumentRef, round Round) {
+	var s string
+	fmt.Println("Announce pairings to Slack? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		err := AnnouncePairings(ctx, tournament, round)
+		if err != nil {
+			log.Println("Error announcing pairings:", err)
+		}
+	}
+}
+
 func main() {
 	// Initialize Firebase.
 	ctx := context.Background()
@@ -621,6 +714,15 @@ func main() {
 		OrderBy("Code", firestore.Asc)
 
 	var s string
+	fmt.Println("Migrate teams to generated IDs? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		err = MigrateTeamIDs(ctx, client, tournament)
+		if err != nil {
+			log.Fatalln("Error migrating team IDs:", err)
+		}
+	}
+
 	fmt.Println("Init? y/n")
 	fmt.Scanln(&s)
 
@@ -629,7 +731,7 @@ func main() {
 		fmt.Println("Init teams? y/n")
 		fmt.Scanln(&s)
 		if s == "y" {
-			n, err := InitTeams(ctx, teams, "spladder-teams.csv")
+			n, err := InitTeams(ctx, tournament, client.Collection("players"), "spladder-teams.csv")
 			if err != nil {
 				log.Fatalln("Error initialising teams:", err)
 			}
@@ -641,11 +743,33 @@ func main() {
 			}
 		}
 
-		// Manually seed the initial ranking.
+		// Seed the initial ranking.
 		fmt.Println("Init ranking? y/n")
 		fmt.Scanln(&s)
 		if s == "y" {
-			err := InitRanking(ctx, teams, ranking)
+			var source, path string
+			fmt.Println("Seed ranking from: 1) manual input 2) team,rank CSV 3) previous tournament 4) rating CSV")
+			fmt.Scanln(&source)
+			switch source {
+			case "2":
+				fmt.Println("Enter the CSV path:")
+				fmt.Scanln(&path)
+				err = SeedRankingFromCSV(ctx, teams, ranking, path)
+			case "3":
+				var previous string
+				fmt.Println("Enter the previous tournament ID (e.g. spladder5):")
+				fmt.Scanln(&previous)
+				err = SeedRankingFromTournament(ctx, teams, ranking, client.Collection("tournaments").Doc(previous))
+			case "4":
+				var column string
+				fmt.Println("Enter the CSV path:")
+				fmt.Scanln(&path)
+				fmt.Println("Enter the rating column name:")
+				fmt.Scanln(&column)
+				err = SeedRankingFromRating(ctx, teams, ranking, path, column)
+			default:
+				err = InitRanking(ctx, teams, ranking)
+			}
 			if err != nil {
 				fmt.Println("Error initialising ranking: ", err)
 			}
@@ -656,22 +780,133 @@ func main() {
 		fmt.Scanln(&s)
 		if s == "y" {
 			CreateChallenges(ctx, tournament, currentRound)
+			announcePairings(ctx, tournament, currentRound)
+		}
+	}
+
+	fmt.Println("Input team availability for the current round? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		err = InputAvailability(ctx, tournament, currentRound)
+		if err != nil {
+			log.Println("Error storing availability:", err)
+		}
+	}
+
+	fmt.Println("Suggest match times for the current round? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		err = SuggestMatchTimes(ctx, tournament, currentRound)
+		if err != nil {
+			log.Println("Error suggesting match times:", err)
+		}
+	}
+
+	fmt.Println("Schedule matches for the current round? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		err = ScheduleMatches(ctx, tournament, currentRound)
+		if err != nil {
+			log.Println("Error scheduling matches:", err)
+		}
+	}
+
+	fmt.Println("Export calendar? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		fmt.Println("Enter the team name, blank for the whole tournament:")
+		name := readLine()
+		teamID, path := "", tournament.ID+".ics"
+		var err error
+		if name != "" {
+			var localTeams []Team
+			localTeams, err = LoadTeams(ctx, teams)
+			if err == nil {
+				team, ok := TeamsByName(localTeams)[name]
+				if ok {
+					teamID = team.ID
+					path = tournament.ID + "-" + teamID + ".ics"
+				} else {
+					err = fmt.Errorf("team %q is not registered", name)
+				}
+			}
+		}
+		if err == nil {
+			err = ExportCalendar(ctx, tournament, teamID, path)
+		}
+		if err != nil {
+			log.Println("Error exporting calendar:", err)
+		} else {
+			fmt.Println("Written to", path)
+		}
+	}
+
+	fmt.Println("Record scores reported through Slack? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		err = ConfirmReports(ctx, tournament, client.Collection("players"), challenges)
+		if err != nil {
+			log.Println("Error confirming scores:", err)
 		}
 	}
 
 	fmt.Println("Input scores for the current round? y/n")
 	fmt.Scanln(&s)
 	if s == "y" {
-		InputScores(ctx, challenges)
+		InputScores(ctx, tournament, client.Collection("players"), challenges)
 	}
 
 	fmt.Println("Generate new ranking based on the previous round scores? y/n")
 	fmt.Scanln(&s)
 	if s == "y" {
-		err = GenerateRanking(ctx, tournament, challenges)
+		result, err := GenerateRanking(ctx, tournament, challenges)
 		if err != nil {
 			log.Fatalln("Error generating ranking:", err)
 		}
+		fmt.Println("Announce results to Slack? y/n")
+		fmt.Scanln(&s)
+		if s == "y" {
+			err = AnnounceResults(ctx, tournament, result)
+			if err != nil {
+				log.Println("Error announcing results:", err)
+			}
+		}
+	}
+
+	fmt.Println("Link Slack users to players from CSV? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		var path string
+		fmt.Println("Enter the path to the handle,slack_user_id CSV:")
+		fmt.Scanln(&path)
+		err = LinkSlackUsers(ctx, client.Collection("players"), path)
+		if err != nil {
+			log.Println("Error linking Slack users:", err)
+		}
+	}
+
+	fmt.Println("Review Slack link requests? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		err = ReviewLinkRequests(ctx, client)
+		if err != nil {
+			log.Println("Error reviewing link requests:", err)
+		}
+	}
+
+	fmt.Println("Show player history? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		var handle string
+		fmt.Println("Enter the player handle:")
+		fmt.Scanln(&handle)
+		history, err := FindPlayerHistory(ctx, client, handle)
+		if err != nil {
+			log.Println("Error reading player history:", err)
+		}
+		for _, h := range history {
+			fmt.Printf("%s: %s\n", h.Tournament, h.Team)
+		}
 	}
 
 	fmt.Println("Add new team? y/n")
@@ -684,6 +919,7 @@ func main() {
 	fmt.Scanln(&s)
 	if s == "y" {
 		CreateChallenges(ctx, tournament, currentRound+1)
+		announcePairings(ctx, tournament, currentRound+1)
 		_, err = tournament.Set(ctx, map[string]interface{}{
 			"currentRound": currentRound + 1,
 		}, firestore.MergeAll)
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+)
+
+// ExportCalendar writes the scheduled challenges of the tournament, or of one team when teamID is set, to a local file.
+func ExportCalendar(ctx context.Context, tournament *firestore.DocumentRef, teamID string, path string) error {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	duration := time.Duration(settings.MatchMinutes) * time.Minute
+	if duration == 0 {
+		duration = time.Hour
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return err
+	}
+
+	docs, err := tournament.Collection("challenges").Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	events := make([]ladder.Event, 0, len(docs))
+	for _, doc := range docs {
+		var challenge Challenge
+		if err = doc.DataTo(&challenge); err != nil {
+			return err
+		}
+		if teamID != "" && challenge.ChallengerID != teamID && challenge.DefenderID != teamID {
+			continue
+		}
+		events = append(events, ladder.Event{ID: doc.Ref.ID, Match: challenge.Match()})
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := ladder.WriteCalendar(f, tournament.ID, tournament.ID, events, duration, loc); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// readLine reads a line from standard input, spaces included, without the line ending.
+// It reads a byte at a time so that nothing after the line is buffered away from the fmt.Scanln prompts.
+func readLine() string {
+	var line strings.Builder
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 0 || err != nil || b[0] == '\n' {
+			break
+		}
+		line.WriteByte(b[0])
+	}
+	return strings.TrimSuffix(line.String(), "\r")
+}
+
+// ParseLineup resolves a comma separated list of handles to player IDs.
+func ParseLineup(input string, registry map[string]Player) ([]string, error) {
+	lineup := make([]string, 0)
+	for _, handle := range strings.Split(input, ",") {
+		if strings.TrimSpace(handle) == "" {
+			continue
+		}
+		p, ok := registry[handleKey(handle)]
+		if !ok {
+			return nil, fmt.Errorf("player %s is not registered", strings.TrimSpace(handle))
+		}
+		lineup = append(lineup, p.ID)
+	}
+	return lineup, nil
+}
+
+// substitutes returns the players in the lineup who are not registered for the team.
+func substitutes(lineup []string, team Team) []string {
+	registered := make(map[string]bool)
+	for _, id := range team.PlayerIDs {
+		registered[id] = true
+	}
+	subs := make([]string, 0)
+	for _, id := range lineup {
+		if !registered[id] {
+			subs = append(subs, id)
+		}
+	}
+	return subs
+}
+
+// CountSubstitutions counts the substitutes a team fielded in the tournament, skipping the challenge given by exclude.
+func CountSubstitutions(ctx context.Context, tournament *firestore.DocumentRef, team Team, exclude string) (int, error) {
+	count := 0
+	iter := tournament.Collection("challenges").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if doc.Ref.ID == exclude {
+			continue
+		}
+		var challenge Challenge
+		if err = doc.DataTo(&challenge); err != nil {
+			return 0, err
+		}
+		if challenge.ChallengerID == team.ID {
+			count += len(substitutes(challenge.ChallengerLineup, team))
+		}
+		if challenge.DefenderID == team.ID {
+			count += len(substitutes(challenge.DefenderLineup, team))
+		}
+	}
+	return count, nil
+}
+
+// ValidateLineup checks a lineup a team fielded for a challenge.
+// Substitutes must not be registered for another team, and the team must stay within the substitution limit.
+func ValidateLineup(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, localTeams []Team,
+	challengeID string, team Team, lineup []string) error {
+	if len(lineup) != minRosterSize {
+		return fmt.Errorf("%s fielded %d players, %d are required", team.Name, len(lineup), minRosterSize)
+	}
+	seen := make(map[string]bool)
+	for _, id := range lineup {
+		if seen[id] {
+			return fmt.Errorf("%s fielded the same player twice", team.Name)
+		}
+		seen[id] = true
+	}
+
+	subs := substitutes(lineup, team)
+	if len(subs) == 0 {
+		return nil
+	}
+	for _, other := range localTeams {
+		if other.ID == team.ID {
+			continue
+		}
+		for _, id := range subs {
+			for _, registered := range other.PlayerIDs {
+				if id == registered {
+					return fmt.Errorf("substitute for %s is registered for %s", team.Name, other.Name)
+				}
+			}
+		}
+	}
+	if settings.MaxSubstitutions == 0 {
+		return nil
+	}
+	used, err := CountSubstitutions(ctx, tournament, team, challengeID)
+	if err != nil {
+		return err
+	}
+	if used+len(subs) > settings.MaxSubstitutions {
+		return fmt.Errorf("%s would use %d substitutions, the limit is %d", team.Name, used+len(subs), settings.MaxSubstitutions)
+	}
+	return nil
+}
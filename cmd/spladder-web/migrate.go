@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// MigrateTeamIDs moves teams keyed by their name to documents keyed by a generated ID.
+// Metrics are moved along with the team, and rankings and challenges are rewritten to refer to the new IDs.
+// Each team is moved in a single batch, so a team is never left half moved, and teams that already have
+// a generated ID are left untouched. The migration can therefore be run again after a failure to finish it.
+func MigrateTeamIDs(ctx context.Context, client *firestore.Client, tournament *firestore.DocumentRef) error {
+	teams := tournament.Collection("teams")
+	idByName := make(map[string]string)
+
+	iter := teams.Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		team := TeamFromDoc(doc)
+		if team.ID != team.Name {
+			idByName[team.Name] = team.ID
+			continue
+		}
+
+		newRef := teams.NewDoc()
+		batch := client.Batch()
+		batch.Set(newRef, doc.Data())
+		mdocs, err := doc.Ref.Collection("metrics").Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, mdoc := range mdocs {
+			var metrics TeamMetadata
+			if err = mdoc.DataTo(&metrics); err != nil {
+				return err
+			}
+			metrics.TeamID = newRef.ID
+			batch.Set(newRef.Collection("metrics").Doc(mdoc.Ref.ID), metrics)
+			batch.Delete(mdoc.Ref)
+		}
+		batch.Delete(doc.Ref)
+		if _, err = batch.Commit(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("Moved %s to %s\n", team.Name, newRef.ID)
+		idByName[team.Name] = newRef.ID
+	}
+
+	// Rewrite rankings that still refer to team names, including teams moved by an earlier run.
+	iter = tournament.Collection("ranking").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rankToUpload := make(map[string]string)
+		changed := false
+		for rank, value := range doc.Data() {
+			team := fmt.Sprintf("%v", value)
+			if id, ok := idByName[team]; ok && id != team {
+				team = id
+				changed = true
+			}
+			rankToUpload[rank] = team
+		}
+		if !changed {
+			continue
+		}
+		if _, err = doc.Ref.Set(ctx, rankToUpload); err != nil {
+			return err
+		}
+		fmt.Println("Rewrote ranking for round", doc.Ref.ID)
+	}
+
+	// Record team IDs on challenges, leaving the other fields as they are.
+	iter = tournament.Collection("challenges").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var challenge Challenge
+		if err = doc.DataTo(&challenge); err != nil {
+			return err
+		}
+		var updates []firestore.Update
+		if id, ok := idByName[challenge.Challenger]; ok && challenge.ChallengerID == "" {
+			updates = append(updates, firestore.Update{Path: "ChallengerID", Value: id})
+		}
+		if id, ok := idByName[challenge.Defender]; ok && challenge.DefenderID == "" {
+			updates = append(updates, firestore.Update{Path: "DefenderID", Value: id})
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		if _, err = doc.Ref.Update(ctx, updates); err != nil {
+			return err
+		}
+		fmt.Println("Recorded team IDs for challenge", doc.Ref.ID)
+	}
+	return nil
+}
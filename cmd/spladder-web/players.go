@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"google.golang.org/api/iterator"
+)
+
+// minRosterSize is the number of players a team needs to play a match.
+const minRosterSize = 4
+
+// Player holds a player registered across tournaments.
+type Player struct {
+	ID          string `firestore:"-"`
+	Handle      string `firestore:"Handle"`
+	DisplayName string `firestore:"DisplayName"`
+	SlackUserID string `firestore:"SlackUserID"`
+	FriendCode  string `firestore:"FriendCode"`
+}
+
+// PlayerHistory records a team a player was registered for.
+type PlayerHistory struct {
+	Tournament string
+	Team       string
+}
+
+// handleKey normalises a handle so that "@Topi" and "topi" refer to the same player.
+func handleKey(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// LoadPlayers reads the player registry, indexed by normalised handle.
+func LoadPlayers(ctx context.Context, players *firestore.CollectionRef) (map[string]Player, error) {
+	registry := make(map[string]Player)
+	iter := players.Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var p Player
+		if err = doc.DataTo(&p); err != nil {
+			return nil, err
+		}
+		p.ID = doc.Ref.ID
+		registry[handleKey(p.Handle)] = p
+	}
+	return registry, nil
+}
+
+// CheckRosterRules checks that every team has enough players and that no player is registered for two teams.
+func CheckRosterRules(localTeams []Team, registry map[string]Player) error {
+	var problems []string
+	playerTeams := make(map[string]string)
+	for _, t := range localTeams {
+		if len(t.Players) < minRosterSize {
+			problems = append(problems, fmt.Sprintf("%s has %d players, at least %d are required", t.Name, len(t.Players), minRosterSize))
+		}
+		for _, handle := range t.Players {
+			key := handleKey(handle)
+			if p, ok := registry[key]; ok {
+				key = p.ID
+			}
+			if team, ok := playerTeams[key]; ok && team != t.Name {
+				problems = append(problems, fmt.Sprintf("%s is registered for both %s and %s", handle, team, t.Name))
+			}
+			playerTeams[key] = t.Name
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("roster rules violated:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// RegisterPlayers records the player IDs on each team, assigning IDs to players missing from the registry.
+// Nothing is written: the missing players are returned so that they can be registered along with the roster.
+func RegisterPlayers(players *firestore.CollectionRef, registry map[string]Player, localTeams []Team) ([]Team, []Player) {
+	assigned := make(map[string]Player)
+	newPlayers := make([]Player, 0)
+	registered := make([]Team, 0, len(localTeams))
+	for _, t := range localTeams {
+		t.PlayerIDs = make([]string, 0, len(t.Players))
+		for _, handle := range t.Players {
+			p, ok := registry[handleKey(handle)]
+			if !ok {
+				p, ok = assigned[handleKey(handle)]
+			}
+			if !ok {
+				p = Player{ID: players.NewDoc().ID, Handle: handle, DisplayName: strings.TrimPrefix(handle, "@")}
+				assigned[handleKey(handle)] = p
+				newPlayers = append(newPlayers, p)
+			}
+			t.PlayerIDs = append(t.PlayerIDs, p.ID)
+		}
+		registered = append(registered, t)
+	}
+	return registered, newPlayers
+}
+
+// FindPlayerHistory lists the teams a player was registered for in every tournament.
+// Teams imported before the registry existed are matched by handle.
+func FindPlayerHistory(ctx context.Context, client *firestore.Client, handle string) ([]PlayerHistory, error) {
+	registry, err := LoadPlayers(ctx, client.Collection("players"))
+	if err != nil {
+		return nil, err
+	}
+	player, ok := registry[handleKey(handle)]
+	if !ok {
+		return nil, fmt.Errorf("player %s is not registered", handle)
+	}
+
+	history := make([]PlayerHistory, 0)
+	iter := client.Collection("tournaments").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		localTeams, err := LoadTeams(ctx, doc.Ref.Collection("teams"))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range localTeams {
+			if t.HasPlayer(player) {
+				history = append(history, PlayerHistory{Tournament: doc.Ref.ID, Team: t.Name})
+			}
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Tournament < history[j].Tournament
+	})
+	return history, nil
+}
+
+// LinkSlackUsers links registered players to their Slack users from a local csv file given by path.
+// Each row holds a handle followed by a Slack user ID, and an optional header row is skipped.
+// A Slack user may only be linked to one player, in the file or in the registry.
+// All problems found in the file are reported together with their line numbers.
+func LinkSlackUsers(ctx context.Context, players *firestore.CollectionRef, path string) error {
+	registry, err := LoadPlayers(ctx, players)
+	if err != nil {
+		return err
+	}
+	csvfile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer csvfile.Close()
+
+	r := csv.NewReader(csvfile)
+	r.FieldsPerRecord = -1
+	// linkedTo maps the Slack users already linked in the registry to their player.
+	linkedTo := make(map[string]Player)
+	for _, p := range registry {
+		if p.SlackUserID != "" {
+			linkedTo[p.SlackUserID] = p
+		}
+	}
+	links := make(map[string]string)
+	slackUsers := make(map[string]int)
+	var problems []string
+	for first := true; ; first = false {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := r.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(row[0]), "handle") {
+			continue
+		}
+		if len(row) < 2 {
+			problems = append(problems, fmt.Sprintf("line %d: expected a handle and a Slack user ID", line))
+			continue
+		}
+		handle := strings.TrimSpace(row[0])
+		slackUserID := strings.TrimSpace(row[1])
+		p, ok := registry[handleKey(handle)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("line %d: player %s is not registered", line, handle))
+			continue
+		}
+		if slackUserID == "" {
+			problems = append(problems, fmt.Sprintf("line %d: missing Slack user ID for %s", line, handle))
+			continue
+		}
+		if prev, ok := slackUsers[slackUserID]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: Slack user %s is already linked on line %d", line, slackUserID, prev))
+			continue
+		}
+		if other, ok := linkedTo[slackUserID]; ok && other.ID != p.ID {
+			problems = append(problems, fmt.Sprintf("line %d: Slack user %s is already linked to %s", line, slackUserID, other.Handle))
+			continue
+		}
+		slackUsers[slackUserID] = line
+		links[p.ID] = slackUserID
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s:\n%s", path, strings.Join(problems, "\n"))
+	}
+
+	for _, p := range registry {
+		slackUserID, ok := links[p.ID]
+		if !ok || slackUserID == p.SlackUserID {
+			continue
+		}
+		if _, err := players.Doc(p.ID).Update(ctx, []firestore.Update{{Path: "SlackUserID", Value: slackUserID}}); err != nil {
+			return err
+		}
+		fmt.Printf("Linked %s to Slack user %s\n", p.Handle, slackUserID)
+	}
+	return nil
+}
+
+// ReviewLinkRequests lets an organiser approve or reject the links requested with "/ladder link".
+// Approving a request links the player to the Slack user unless either has been linked since.
+func ReviewLinkRequests(ctx context.Context, client *firestore.Client) error {
+	players := client.Collection("players")
+	iter := client.Collection("linkRequests").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req ladder.LinkRequest
+		if err = doc.DataTo(&req); err != nil {
+			return err
+		}
+
+		fmt.Printf("Slack user %s (%s) requests %s (requested %s)\n", req.SlackUserName, req.SlackUserID, req.Handle,
+			req.RequestedAt.Format(dateLayout))
+		fmt.Println("Approve? y/n, r to reject")
+		var s string
+		fmt.Scanln(&s)
+		switch s {
+		case "y":
+		case "r":
+			if _, err = doc.Ref.Delete(ctx); err != nil {
+				return err
+			}
+			fmt.Println("Rejected.")
+			continue
+		default:
+			continue
+		}
+
+		linked, err := players.Where("SlackUserID", "==", req.SlackUserID).Limit(1).Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(linked) > 0 && linked[0].Ref.ID != req.PlayerID {
+			fmt.Printf("Slack user %s is already linked to player %s; not linked.\n", req.SlackUserID, linked[0].Ref.ID)
+			continue
+		}
+		err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			ref := players.Doc(req.PlayerID)
+			pdoc, err := tx.Get(ref)
+			if err != nil {
+				return err
+			}
+			var p Player
+			if err = pdoc.DataTo(&p); err != nil {
+				return err
+			}
+			if p.SlackUserID != "" && p.SlackUserID != req.SlackUserID {
+				return fmt.Errorf("%s is already linked to Slack user %s", p.Handle, p.SlackUserID)
+			}
+			if err = tx.Update(ref, []firestore.Update{{Path: "SlackUserID", Value: req.SlackUserID}}); err != nil {
+				return err
+			}
+			return tx.Delete(doc.Ref)
+		})
+		if err != nil {
+			fmt.Printf("Not linked: %s\n", err)
+			continue
+		}
+		fmt.Printf("Linked %s to Slack user %s\n", req.Handle, req.SlackUserID)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"google.golang.org/api/iterator"
+)
+
+// ConfirmReports lets an organiser record or dispute the scores reported through Slack for the challenges.
+// Recording a score requires the lineups, which are validated as for scores entered with InputScores.
+func ConfirmReports(ctx context.Context, tournament *firestore.DocumentRef, players *firestore.CollectionRef, challenges firestore.Query) error {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	localTeams, err := LoadTeams(ctx, tournament.Collection("teams"))
+	if err != nil {
+		return err
+	}
+	byID := TeamsByID(localTeams)
+	registry, err := LoadPlayers(ctx, players)
+	if err != nil {
+		return err
+	}
+
+	iter := challenges.Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var challenge Challenge
+		if err = doc.DataTo(&challenge); err != nil {
+			return err
+		}
+		if !challenge.Unrecorded() {
+			continue
+		}
+
+		fmt.Printf("[%d-%d] Div %s: %s %d - %d %s (%s, reported by %s)\n", challenge.Round, challenge.Code,
+			challenge.Division.String(), challenge.Challenger, challenge.ReportedChallengerScore,
+			challenge.ReportedDefenderScore, challenge.Defender, challenge.ReportStatus, challenge.ReportedBy)
+		fmt.Println("Record? y/n, d to dispute")
+		var s string
+		fmt.Scanln(&s)
+		var updates []firestore.Update
+		switch {
+		case s == "y":
+			var challengerLineup, defenderLineup []string
+			for {
+				challengerLineup, defenderLineup, err = inputLineups(ctx, tournament, settings, localTeams, registry,
+					doc.Ref.ID, byID[challenge.ChallengerID], byID[challenge.DefenderID])
+				if err == nil || !askRetry(fmt.Sprintf("Invalid lineup: %s.", err)) {
+					break
+				}
+			}
+			if err != nil {
+				continue
+			}
+			updates = []firestore.Update{
+				{Path: "ChallengerScore", Value: challenge.ReportedChallengerScore},
+				{Path: "DefenderScore", Value: challenge.ReportedDefenderScore},
+				{Path: "ChallengerLineup", Value: challengerLineup},
+				{Path: "DefenderLineup", Value: defenderLineup},
+				{Path: "ReportStatus", Value: ladder.ReportRecorded},
+				{Path: "Flag", Value: firestore.Delete},
+				{Path: "Forfeit", Value: firestore.Delete},
+			}
+			// Keep who confirmed a score through Slack.
+			if challenge.ReportStatus != ladder.ReportConfirmed {
+				updates = append(updates,
+					firestore.Update{Path: "ConfirmedBy", Value: "organiser"},
+					firestore.Update{Path: "ConfirmedAt", Value: time.Now()})
+			}
+		case s == "d" && challenge.ReportStatus == ladder.ReportPending:
+			updates = []firestore.Update{
+				{Path: "ReportStatus", Value: ladder.ReportDisputed},
+				{Path: "Flag", Value: ladder.ReportDisputed},
+			}
+		default:
+			continue
+		}
+		if _, err = doc.Ref.Update(ctx, updates); err != nil {
+			return err
+		}
+		fmt.Println("Written to firebase.")
+	}
+}
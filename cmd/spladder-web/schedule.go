@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dateLayout is the layout used to enter dates on the command line.
+const dateLayout = "2006-01-02T15:04"
+
+// RoundWindow holds the period in which the challenges of a round must be played.
+// End is also the deadline for reporting results.
+type RoundWindow struct {
+	Round Round     `firestore:"Round"`
+	Start time.Time `firestore:"Start"`
+	End   time.Time `firestore:"End"`
+}
+
+// Contains reports whether t falls within the window, as ladder.Window.Contains does for the Slack commands.
+func (w RoundWindow) Contains(t time.Time) bool {
+	return ladder.Window{Start: w.Start, End: w.End}.Contains(t)
+}
+
+// LoadRoundWindow reads the window of a round. A round without a window yields an empty window.
+func LoadRoundWindow(ctx context.Context, tournament *firestore.DocumentRef, round Round) (RoundWindow, error) {
+	w := RoundWindow{Round: round}
+	doc, err := tournament.Collection("rounds").Doc(round.String()).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return w, nil
+	}
+	if err != nil {
+		return w, err
+	}
+	err = doc.DataTo(&w)
+	return w, err
+}
+
+// SetRoundWindow stores the window of a round.
+func SetRoundWindow(ctx context.Context, tournament *firestore.DocumentRef, w RoundWindow) error {
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("round %d ends before it starts", w.Round)
+	}
+	_, err := tournament.Collection("rounds").Doc(w.Round.String()).Set(ctx, map[string]interface{}{
+		"Round": w.Round,
+		"Start": w.Start,
+		"End":   w.End,
+	}, firestore.MergeAll)
+	return err
+}
+
+// loadChallenge reads a challenge by its key, e.g. "3-2".
+func loadChallenge(ctx context.Context, tournament *firestore.DocumentRef, key string) (*firestore.DocumentRef, Challenge, error) {
+	var challenge Challenge
+	ref := tournament.Collection("challenges").Doc(key)
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return ref, challenge, err
+	}
+	err = doc.DataTo(&challenge)
+	return ref, challenge, err
+}
+
+// validateMatchTime checks that a match time falls within the window of the challenge's round.
+func validateMatchTime(ctx context.Context, tournament *firestore.DocumentRef, challenge Challenge, t time.Time) error {
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return err
+	}
+	w, err := LoadRoundWindow(ctx, tournament, challenge.Round)
+	if err != nil {
+		return err
+	}
+	if !w.Contains(t) {
+		return fmt.Errorf("%s is outside round %d (%s - %s)", t.In(loc).Format(dateLayout), challenge.Round,
+			w.Start.In(loc).Format(dateLayout), w.End.In(loc).Format(dateLayout))
+	}
+	return nil
+}
+
+// ProposeMatchTime records a match time proposed by one of the teams of a challenge.
+func ProposeMatchTime(ctx context.Context, tournament *firestore.DocumentRef, key string, teamID string, t time.Time) error {
+	ref, challenge, err := loadChallenge(ctx, tournament, key)
+	if err != nil {
+		return err
+	}
+	if teamID != challenge.ChallengerID && teamID != challenge.DefenderID {
+		return fmt.Errorf("team %s does not play challenge %s", teamID, key)
+	}
+	if err := validateMatchTime(ctx, tournament, challenge, t); err != nil {
+		return err
+	}
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "ProposedDate", Value: t},
+		{Path: "ProposedBy", Value: teamID},
+	})
+	return err
+}
+
+// ConfirmMatchTime schedules a challenge at its proposed time.
+func ConfirmMatchTime(ctx context.Context, tournament *firestore.DocumentRef, key string) error {
+	ref, challenge, err := loadChallenge(ctx, tournament, key)
+	if err != nil {
+		return err
+	}
+	if challenge.ProposedDate.IsZero() {
+		return fmt.Errorf("no time has been proposed for challenge %s", key)
+	}
+	if err := validateMatchTime(ctx, tournament, challenge, challenge.ProposedDate); err != nil {
+		return err
+	}
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "Date", Value: challenge.ProposedDate},
+		{Path: "ProposedDate", Value: firestore.Delete},
+		{Path: "ProposedBy", Value: firestore.Delete},
+	})
+	return err
+}
+
+// RescheduleMatch moves a challenge to a new time and drops any pending proposal.
+func RescheduleMatch(ctx context.Context, tournament *firestore.DocumentRef, key string, t time.Time) error {
+	ref, challenge, err := loadChallenge(ctx, tournament, key)
+	if err != nil {
+		return err
+	}
+	if err := validateMatchTime(ctx, tournament, challenge, t); err != nil {
+		return err
+	}
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "Date", Value: t},
+		{Path: "ProposedDate", Value: firestore.Delete},
+		{Path: "ProposedBy", Value: firestore.Delete},
+	})
+	return err
+}
+
+// ScheduleMatches sets the round window and schedules challenges based on user input.
+// Times are entered in the time zone of the tournament.
+func ScheduleMatches(ctx context.Context, tournament *firestore.DocumentRef, round Round) error {
+	var s string
+	settings, err := LoadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	loc, err := settings.Location()
+	if err != nil {
+		return err
+	}
+	w, err := LoadRoundWindow(ctx, tournament, round)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Round %d window (%s): %s - %s\n", round, loc, w.Start.In(loc).Format(dateLayout), w.End.In(loc).Format(dateLayout))
+	fmt.Println("Set round window? y/n")
+	fmt.Scanln(&s)
+	if s == "y" {
+		var start, end string
+		fmt.Printf("Enter the round start (%s):\n", dateLayout)
+		fmt.Scanln(&start)
+		fmt.Printf("Enter the round end, which is also the deadline for results (%s):\n", dateLayout)
+		fmt.Scanln(&end)
+		if w.Start, err = time.ParseInLocation(dateLayout, start, loc); err != nil {
+			return err
+		}
+		if w.End, err = time.ParseInLocation(dateLayout, end, loc); err != nil {
+			return err
+		}
+		if err := SetRoundWindow(ctx, tournament, w); err != nil {
+			return err
+		}
+	}
+
+	localTeams, err := LoadTeams(ctx, tournament.Collection("teams"))
+	if err != nil {
+		return err
+	}
+	byName := TeamsByName(localTeams)
+	for {
+		var key, action, date string
+		fmt.Println("Enter the challenge to schedule (e.g. 3-2), blank to finish:")
+		fmt.Scanln(&key)
+		if key == "" {
+			return nil
+		}
+		fmt.Println("1) propose 2) confirm 3) reschedule")
+		fmt.Scanln(&action)
+		if action == "1" || action == "3" {
+			fmt.Printf("Enter the match time (%s):\n", dateLayout)
+			fmt.Scanln(&date)
+		}
+		t, err := time.ParseInLocation(dateLayout, date, loc)
+		switch action {
+		case "1":
+			if err != nil {
+				break
+			}
+			fmt.Println("Proposed by (team name):")
+			name := readLine()
+			team, ok := byName[name]
+			if !ok {
+				err = fmt.Errorf("team %q is not registered", name)
+				break
+			}
+			err = ProposeMatchTime(ctx, tournament, key, team.ID, t)
+		case "2":
+			err = ConfirmMatchTime(ctx, tournament, key)
+		case "3":
+			if err != nil {
+				break
+			}
+			err = RescheduleMatch(ctx, tournament, key, t)
+		default:
+			err = fmt.Errorf("unknown action %q", action)
+		}
+		if err != nil {
+			fmt.Println("Error scheduling challenge:", err)
+			continue
+		}
+		fmt.Println("Written to firebase.")
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// ValidateRanking checks that ranks form 1..N exactly and that every team appears once.
+// The ranking maps each rank to a team ID.
+func ValidateRanking(ranking map[int]string, localTeams []Team) error {
+	byID := TeamsByID(localTeams)
+	if len(ranking) != len(localTeams) {
+		return fmt.Errorf("ranking has %d entries but %d teams are registered", len(ranking), len(localTeams))
+	}
+	seen := make(map[string]int)
+	for rank := 1; rank <= len(localTeams); rank++ {
+		id, ok := ranking[rank]
+		if !ok {
+			return fmt.Errorf("rank %d is missing", rank)
+		}
+		team, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("rank %d: unknown team %q", rank, id)
+		}
+		if prev, ok := seen[id]; ok {
+			return fmt.Errorf("team %q has both rank %d and rank %d", team.Name, prev, rank)
+		}
+		seen[id] = rank
+	}
+	return nil
+}
+
+// UploadRanking writes the ranking to the given ranking document, replacing its contents.
+func UploadRanking(ctx context.Context, ranking *firestore.DocumentRef, localRank map[int]string) error {
+	rankToUpload := make(map[string]string)
+	for rank, team := range localRank {
+		rankToUpload[strconv.Itoa(rank)] = team
+	}
+	_, err := ranking.Set(ctx, rankToUpload)
+	return err
+}
+
+func printRanking(localRank map[int]string, localTeams []Team) {
+	byID := TeamsByID(localTeams)
+	for rank := 1; rank <= len(localRank); rank++ {
+		fmt.Println(rank, byID[localRank[rank]].Name)
+	}
+}
+
+// rankByName converts a ranking of team names to a ranking of team IDs.
+func rankByName(byName map[int]string, localTeams []Team) (map[int]string, error) {
+	index := TeamsByName(localTeams)
+	localRank := make(map[int]string)
+	for rank, name := range byName {
+		team, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("rank %d: unknown team %q", rank, name)
+		}
+		localRank[rank] = team.ID
+	}
+	return localRank, nil
+}
+
+// SeedRankingFromCSV seeds the ranking from a local csv file of team,rank rows.
+func SeedRankingFromCSV(ctx context.Context, teams *firestore.CollectionRef, ranking *firestore.DocumentRef, path string) error {
+	localTeams, err := LoadTeams(ctx, teams)
+	if err != nil {
+		return err
+	}
+	csvfile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer csvfile.Close()
+
+	r := csv.NewReader(csvfile)
+	r.FieldsPerRecord = 2
+	rows, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	names := make(map[int]string)
+	for i, row := range rows {
+		team := strings.TrimSpace(row[0])
+		rank, err := strconv.Atoi(strings.TrimSpace(row[1]))
+		if err != nil {
+			// Allow a header row such as "team,rank".
+			if i == 0 {
+				continue
+			}
+			return fmt.Errorf("line %d: invalid rank %q", i+1, row[1])
+		}
+		if other, ok := names[rank]; ok {
+			return fmt.Errorf("line %d: rank %d is already held by %s", i+1, rank, other)
+		}
+		names[rank] = team
+	}
+	localRank, err := rankByName(names, localTeams)
+	if err != nil {
+		return err
+	}
+	if err := ValidateRanking(localRank, localTeams); err != nil {
+		return err
+	}
+	printRanking(localRank, localTeams)
+	return UploadRanking(ctx, ranking, localRank)
+}
+
+// SeedRankingFromTournament seeds the ranking from the final ranking of a previous tournament.
+// Teams are matched by name, and teams that did not take part in the previous tournament are appended at the bottom.
+func SeedRankingFromTournament(ctx context.Context, teams *firestore.CollectionRef, ranking *firestore.DocumentRef, previous *firestore.DocumentRef) error {
+	localTeams, err := LoadTeams(ctx, teams)
+	if err != nil {
+		return err
+	}
+	byName := TeamsByName(localTeams)
+	previousTeams, err := LoadTeams(ctx, previous.Collection("teams"))
+	if err != nil {
+		return err
+	}
+	previousByID := TeamsByID(previousTeams)
+
+	// The final ranking is the one for the latest round.
+	var final *firestore.DocumentSnapshot
+	var finalRound int
+	iter := previous.Collection("ranking").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		round, err := strconv.Atoi(doc.Ref.ID)
+		if err != nil {
+			continue
+		}
+		if final == nil || round > finalRound {
+			final = doc
+			finalRound = round
+		}
+	}
+	if final == nil {
+		return fmt.Errorf("no ranking found for tournament %s", previous.ID)
+	}
+	fmt.Printf("Using ranking for round %d of %s\n", finalRound, previous.ID)
+
+	previousRank := make(map[int]string)
+	for key, value := range final.Data() {
+		rank, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("invalid rank %q in %s", key, final.Ref.Path)
+		}
+		previousRank[rank] = fmt.Sprintf("%v", value)
+	}
+	ranks := make([]int, 0, len(previousRank))
+	for rank := range previousRank {
+		ranks = append(ranks, rank)
+	}
+	sort.Ints(ranks)
+
+	localRank := make(map[int]string)
+	placed := make(map[string]bool)
+	for _, rank := range ranks {
+		team, ok := byName[previousByID[previousRank[rank]].Name]
+		if !ok || placed[team.ID] {
+			continue
+		}
+		localRank[len(localRank)+1] = team.ID
+		placed[team.ID] = true
+	}
+	for _, team := range localTeams {
+		if !placed[team.ID] {
+			fmt.Printf("%s did not take part in %s; placing at rank %d\n", team.Name, previous.ID, len(localRank)+1)
+			localRank[len(localRank)+1] = team.ID
+		}
+	}
+	if err := ValidateRanking(localRank, localTeams); err != nil {
+		return err
+	}
+	printRanking(localRank, localTeams)
+	return UploadRanking(ctx, ranking, localRank)
+}
+
+// SeedRankingFromRating seeds the ranking from a rating column of a local csv file with a header row.
+// The first column holds the team name and teams are ranked by descending rating.
+func SeedRankingFromRating(ctx context.Context, teams *firestore.CollectionRef, ranking *firestore.DocumentRef, path string, column string) error {
+	localTeams, err := LoadTeams(ctx, teams)
+	if err != nil {
+		return err
+	}
+	csvfile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer csvfile.Close()
+
+	r := csv.NewReader(csvfile)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("%s is empty", path)
+	}
+	col := -1
+	for i, name := range rows[0] {
+		if strings.TrimSpace(name) == column {
+			col = i
+		}
+	}
+	if col < 0 {
+		return fmt.Errorf("column %q not found in %s", column, path)
+	}
+
+	type rated struct {
+		team   string
+		rating float64
+	}
+	ratings := make([]rated, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		if len(row) <= col {
+			return fmt.Errorf("line %d: missing %s", i+2, column)
+		}
+		rating, err := strconv.ParseFloat(strings.TrimSpace(row[col]), 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid %s %q", i+2, column, row[col])
+		}
+		ratings = append(ratings, rated{team: strings.TrimSpace(row[0]), rating: rating})
+	}
+	// Ties keep the order of the csv file.
+	sort.SliceStable(ratings, func(i, j int) bool {
+		return ratings[i].rating > ratings[j].rating
+	})
+
+	names := make(map[int]string)
+	for i, r := range ratings {
+		names[i+1] = r.team
+	}
+	localRank, err := rankByName(names, localTeams)
+	if err != nil {
+		return err
+	}
+	if err := ValidateRanking(localRank, localTeams); err != nil {
+		return err
+	}
+	printRanking(localRank, localTeams)
+	return UploadRanking(ctx, ranking, localRank)
+}
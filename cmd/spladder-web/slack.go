@@ -0,0 +1,8 @@
+package main
+
+import "github.com/knagayama/ladder-firebase/ladder"
+
+// SlackConfig resolves the Slack destination of the tournament from the environment variables it names.
+func (t Tournament) SlackConfig() ladder.SlackConfig {
+	return ladder.ResolveSlackConfig(t.SlackWebhookEnv, t.SlackTokenEnv, t.SlackChannel)
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// Team holds the roster of a team.
+// The ID is the key of the team document and stays the same when the team is renamed.
+// Players holds the handles of the players and PlayerIDs their IDs in the player registry.
+type Team struct {
+	ID        string
+	Name      string
+	Players   []string
+	PlayerIDs []string
+}
+
+// Doc returns the Firestore document for the team.
+func (t Team) Doc() map[string]interface{} {
+	doc := map[string]interface{}{"name": t.Name}
+	for i, player := range t.Players {
+		doc["player"+strconv.Itoa(i+1)] = player
+	}
+	if len(t.PlayerIDs) > 0 {
+		doc["playerIDs"] = t.PlayerIDs
+	}
+	return doc
+}
+
+// HasPlayer reports whether the player is registered for the team.
+func (t Team) HasPlayer(p Player) bool {
+	for _, id := range t.PlayerIDs {
+		if id == p.ID {
+			return true
+		}
+	}
+	for _, handle := range t.Players {
+		if handleKey(handle) == handleKey(p.Handle) {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamFromDoc builds a team from a team document.
+func TeamFromDoc(doc *firestore.DocumentSnapshot) Team {
+	t := TeamFromData(doc.Data())
+	t.ID = doc.Ref.ID
+	return t
+}
+
+// TeamFromData builds a team from the fields of a team document.
+func TeamFromData(data map[string]interface{}) Team {
+	var t Team
+	if name, ok := data["name"].(string); ok {
+		t.Name = name
+	}
+	for i := 1; ; i++ {
+		player, ok := data["player"+strconv.Itoa(i)].(string)
+		if !ok {
+			break
+		}
+		if player != "" {
+			t.Players = append(t.Players, player)
+		}
+	}
+	if ids, ok := data["playerIDs"].([]interface{}); ok {
+		for _, id := range ids {
+			t.PlayerIDs = append(t.PlayerIDs, fmt.Sprintf("%v", id))
+		}
+	}
+	return t
+}
+
+// isHeader reports whether a csv row is a header row such as "name,player1,player2,...".
+func isHeader(row []string) bool {
+	if len(row) == 0 {
+		return false
+	}
+	first := strings.ToLower(strings.TrimSpace(row[0]))
+	return first == "name" || first == "team"
+}
+
+// ReadTeamsCSV reads a team roster from a local csv file given by path.
+// Each row holds a team name followed by any number of players, and an optional header row is skipped.
+// All problems found in the file are reported together with their line numbers.
+func ReadTeamsCSV(path string) ([]Team, error) {
+	csvfile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer csvfile.Close()
+
+	r := csv.NewReader(csvfile)
+	r.FieldsPerRecord = -1
+
+	teams := make([]Team, 0)
+	teamLines := make(map[string]int)
+	playerTeams := make(map[string]string)
+	playerLines := make(map[string]int)
+	var problems []string
+	for first := true; ; first = false {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if first && isHeader(row) {
+			continue
+		}
+
+		var t Team
+		t.Name = strings.TrimSpace(row[0])
+		if t.Name == "" {
+			problems = append(problems, fmt.Sprintf("line %d: missing team name", line))
+			continue
+		}
+		if strings.Contains(t.Name, "/") {
+			problems = append(problems, fmt.Sprintf("line %d: team name %q must not contain \"/\"", line, t.Name))
+		}
+		if prev, ok := teamLines[t.Name]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: team %q is already registered on line %d", line, t.Name, prev))
+			continue
+		}
+		teamLines[t.Name] = line
+
+		for _, cell := range row[1:] {
+			player := strings.TrimSpace(cell)
+			if player == "" {
+				continue
+			}
+			if team, ok := playerTeams[player]; ok {
+				problems = append(problems, fmt.Sprintf("line %d: player %s is already registered for %s on line %d",
+					line, player, team, playerLines[player]))
+				continue
+			}
+			playerTeams[player] = t.Name
+			playerLines[player] = line
+			t.Players = append(t.Players, player)
+		}
+		if len(t.Players) == 0 {
+			problems = append(problems, fmt.Sprintf("line %d: team %q has no players", line, t.Name))
+		}
+		teams = append(teams, t)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid team roster %s:\n%s", path, strings.Join(problems, "\n"))
+	}
+	return teams, nil
+}
+
+// LoadTeams reads all teams registered in the tournament, sorted by name.
+func LoadTeams(ctx context.Context, teams *firestore.CollectionRef) ([]Team, error) {
+	localTeams := make([]Team, 0)
+	iter := teams.Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		localTeams = append(localTeams, TeamFromDoc(doc))
+	}
+	sort.Slice(localTeams, func(i, j int) bool {
+		return localTeams[i].Name < localTeams[j].Name
+	})
+	return localTeams, nil
+}
+
+// TeamsByID indexes teams by their ID.
+func TeamsByID(localTeams []Team) map[string]Team {
+	index := make(map[string]Team)
+	for _, t := range localTeams {
+		index[t.ID] = t
+	}
+	return index
+}
+
+// TeamsByName indexes teams by their name.
+func TeamsByName(localTeams []Team) map[string]Team {
+	index := make(map[string]Team)
+	for _, t := range localTeams {
+		index[t.Name] = t
+	}
+	return index
+}
+
+// TeamRename records a team that was registered again under a new name.
+type TeamRename struct {
+	From Team
+	To   Team
+}
+
+// RosterChange records players joining or leaving a team.
+type RosterChange struct {
+	Team   Team
+	Joined []string
+	Left   []string
+}
+
+// RosterDiff holds the changes between the registered teams and an imported roster.
+type RosterDiff struct {
+	Added   []Team
+	Removed []Team
+	Renamed []TeamRename
+	Changed []RosterChange
+	// NewPlayers are the players to add to the registry, as returned by RegisterPlayers.
+	NewPlayers []Player
+}
+
+// Empty reports whether the diff holds no changes.
+func (d RosterDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0 && len(d.Changed) == 0 &&
+		len(d.NewPlayers) == 0
+}
+
+// HasNewPlayers reports whether the diff adds players to the tournament.
+func (d RosterDiff) HasNewPlayers() bool {
+	if len(d.Added) > 0 {
+		return true
+	}
+	for _, c := range d.Changed {
+		if len(c.Joined) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (d RosterDiff) String() string {
+	var b strings.Builder
+	for _, t := range d.Added {
+		fmt.Fprintf(&b, "+ %s: %s\n", t.Name, strings.Join(t.Players, ", "))
+	}
+	for _, t := range d.Removed {
+		fmt.Fprintf(&b, "- %s: %s\n", t.Name, strings.Join(t.Players, ", "))
+	}
+	for _, r := range d.Renamed {
+		fmt.Fprintf(&b, "~ %s -> %s\n", r.From.Name, r.To.Name)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "* %s:", c.Team.Name)
+		for _, p := range c.Joined {
+			fmt.Fprintf(&b, " +%s", p)
+		}
+		for _, p := range c.Left {
+			fmt.Fprintf(&b, " -%s", p)
+		}
+		if len(c.Joined) == 0 && len(c.Left) == 0 {
+			b.WriteString(" players linked to the registry")
+		}
+		b.WriteString("\n")
+	}
+	for _, p := range d.NewPlayers {
+		fmt.Fprintf(&b, "new player %s\n", p.Handle)
+	}
+	return b.String()
+}
+
+// playerChanges returns the players only in to and the players only in from.
+func playerChanges(from, to Team) (joined, left []string) {
+	before := make(map[string]bool)
+	for _, p := range from.Players {
+		before[p] = true
+	}
+	after := make(map[string]bool)
+	for _, p := range to.Players {
+		after[p] = true
+		if !before[p] {
+			joined = append(joined, p)
+		}
+	}
+	for _, p := range from.Players {
+		if !after[p] {
+			left = append(left, p)
+		}
+	}
+	return joined, left
+}
+
+// sameIDs reports whether both lists hold the same IDs in the same order.
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffTeams compares the registered teams with an imported roster.
+// A removed team and an added team are treated as a rename when at least half of the removed team's players remain.
+// Imported teams that match a registered team take over its ID.
+func DiffTeams(existing, imported []Team) RosterDiff {
+	var d RosterDiff
+	old := make(map[string]Team)
+	for _, t := range existing {
+		old[t.Name] = t
+	}
+	seen := make(map[string]bool)
+	var added []Team
+	for _, t := range imported {
+		seen[t.Name] = true
+		prev, ok := old[t.Name]
+		if !ok {
+			added = append(added, t)
+			continue
+		}
+		t.ID = prev.ID
+		joined, left := playerChanges(prev, t)
+		if len(joined) > 0 || len(left) > 0 || !sameIDs(prev.PlayerIDs, t.PlayerIDs) {
+			d.Changed = append(d.Changed, RosterChange{Team: t, Joined: joined, Left: left})
+		}
+	}
+	var removed []Team
+	for _, t := range existing {
+		if !seen[t.Name] {
+			removed = append(removed, t)
+		}
+	}
+
+	renamedTo := make(map[string]bool)
+	for _, from := range removed {
+		best, bestKept := -1, 0
+		for i, to := range added {
+			if renamedTo[to.Name] {
+				continue
+			}
+			_, left := playerChanges(from, to)
+			kept := len(from.Players) - len(left)
+			if kept > bestKept {
+				best, bestKept = i, kept
+			}
+		}
+		if best < 0 || bestKept*2 < len(from.Players) {
+			d.Removed = append(d.Removed, from)
+			continue
+		}
+		to := added[best]
+		to.ID = from.ID
+		renamedTo[to.Name] = true
+		d.Renamed = append(d.Renamed, TeamRename{From: from, To: to})
+		joined, left := playerChanges(from, to)
+		if len(joined) > 0 || len(left) > 0 {
+			d.Changed = append(d.Changed, RosterChange{Team: to, Joined: joined, Left: left})
+		}
+	}
+	for _, t := range added {
+		if !renamedTo[t.Name] {
+			d.Added = append(d.Added, t)
+		}
+	}
+	return d
+}
+
+// ApplyRosterDiff writes the roster diff to Firestore, registering the new players first.
+// Renamed teams keep their document, so their metrics and ranking history are preserved.
+// Removed teams are deleted along with their metrics.
+func ApplyRosterDiff(ctx context.Context, players *firestore.CollectionRef, teams *firestore.CollectionRef, d RosterDiff) error {
+	for _, p := range d.NewPlayers {
+		if _, err := players.Doc(p.ID).Set(ctx, p); err != nil {
+			return err
+		}
+		fmt.Println("Registered player", p.Handle)
+	}
+	for _, t := range d.Added {
+		if _, err := teams.NewDoc().Set(ctx, t.Doc()); err != nil {
+			return err
+		}
+	}
+	for _, r := range d.Renamed {
+		if _, err := teams.Doc(r.To.ID).Set(ctx, r.To.Doc()); err != nil {
+			return err
+		}
+	}
+	for _, c := range d.Changed {
+		if _, err := teams.Doc(c.Team.ID).Set(ctx, c.Team.Doc()); err != nil {
+			return err
+		}
+	}
+	for _, t := range d.Removed {
+		if err := deleteTeam(ctx, teams.Doc(t.ID)); err != nil {
+			return err
+		}
+		fmt.Println("Removed team", t.Name)
+	}
+	return nil
+}
+
+// deleteTeam deletes a team document along with its metrics and availability,
+// which would otherwise be left behind without a team to refer to.
+// The team document goes last, so an interrupted removal can be run again.
+func deleteTeam(ctx context.Context, team *firestore.DocumentRef) error {
+	for _, sub := range []string{"metrics", "availability"} {
+		docs, err := team.Collection(sub).Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if _, err := doc.Ref.Delete(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := team.Delete(ctx)
+	return err
+}
+
+// LatestRanking returns the rank of each team ID in the ranking of the latest round, along with that round.
+// It returns no ranks when no ranking has been uploaded yet.
+func LatestRanking(ctx context.Context, tournament *firestore.DocumentRef) (map[string]int, Round, error) {
+	docs, err := tournament.Collection("ranking").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, 0, err
+	}
+	var latest *firestore.DocumentSnapshot
+	var round Round
+	for _, doc := range docs {
+		r, err := strconv.Atoi(doc.Ref.ID)
+		if err != nil {
+			continue
+		}
+		if latest == nil || Round(r) > round {
+			latest, round = doc, Round(r)
+		}
+	}
+	ranks := make(map[string]int)
+	if latest == nil {
+		return ranks, 0, nil
+	}
+	for key, value := range latest.Data() {
+		rank, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		ranks[fmt.Sprintf("%v", value)] = rank
+	}
+	return ranks, round, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import "testing"
+
+func TestDiffTeams(t *testing.T) {
+	octo := Team{ID: "t1", Name: "Team Octo", Players: []string{"a", "b", "c", "d"}}
+	squid := Team{ID: "t2", Name: "イカ研究所", Players: []string{"e", "f", "g", "h"}}
+	tests := []struct {
+		name     string
+		existing []Team
+		imported []Team
+		want     string
+		// ids maps team names in the diff to the ID they must carry.
+		ids map[string]string
+	}{
+		{
+			name:     "unchanged",
+			existing: []Team{octo, squid},
+			imported: []Team{{Name: "Team Octo", Players: []string{"a", "b", "c", "d"}}, {Name: "イカ研究所", Players: []string{"e", "f", "g", "h"}}},
+			want:     "",
+		},
+		{
+			name:     "added",
+			existing: []Team{octo},
+			imported: []Team{{Name: "Team Octo", Players: []string{"a", "b", "c", "d"}}, {Name: "イカ研究所", Players: []string{"e", "f", "g", "h"}}},
+			want:     "+ イカ研究所: e, f, g, h\n",
+			ids:      map[string]string{"イカ研究所": ""},
+		},
+		{
+			name:     "removed",
+			existing: []Team{octo, squid},
+			imported: []Team{{Name: "Team Octo", Players: []string{"a", "b", "c", "d"}}},
+			want:     "- イカ研究所: e, f, g, h\n",
+			ids:      map[string]string{"イカ研究所": "t2"},
+		},
+		{
+			name:     "players changed",
+			existing: []Team{octo},
+			imported: []Team{{Name: "Team Octo", Players: []string{"a", "b", "c", "x"}}},
+			want:     "* Team Octo: +x -d\n",
+			ids:      map[string]string{"Team Octo": "t1"},
+		},
+		{
+			name:     "players linked",
+			existing: []Team{octo},
+			imported: []Team{{Name: "Team Octo", Players: []string{"a", "b", "c", "d"}, PlayerIDs: []string{"p1", "p2", "p3", "p4"}}},
+			want:     "* Team Octo: players linked to the registry\n",
+			ids:      map[string]string{"Team Octo": "t1"},
+		},
+		{
+			name:     "renamed",
+			existing: []Team{octo},
+			imported: []Team{{Name: "Octo Expansion", Players: []string{"a", "b", "c", "d"}}},
+			want:     "~ Team Octo -> Octo Expansion\n",
+			ids:      map[string]string{"Octo Expansion": "t1"},
+		},
+		{
+			name:     "renamed keeping half the players",
+			existing: []Team{octo},
+			imported: []Team{{Name: "Octo Expansion", Players: []string{"a", "b", "x", "y"}}},
+			want:     "~ Team Octo -> Octo Expansion\n* Octo Expansion: +x +y -c -d\n",
+			ids:      map[string]string{"Octo Expansion": "t1"},
+		},
+		{
+			name:     "replaced by a new team",
+			existing: []Team{octo},
+			imported: []Team{{Name: "Octo Expansion", Players: []string{"a", "x", "y", "z"}}},
+			want:     "+ Octo Expansion: a, x, y, z\n- Team Octo: a, b, c, d\n",
+			ids:      map[string]string{"Octo Expansion": "", "Team Octo": "t1"},
+		},
+		{
+			name:     "renamed to the team keeping the most players",
+			existing: []Team{octo},
+			imported: []Team{
+				{Name: "Octo Expansion", Players: []string{"a", "b", "x", "y"}},
+				{Name: "Octo Canyon", Players: []string{"a", "b", "c", "z"}},
+			},
+			want: "+ Octo Expansion: a, b, x, y\n~ Team Octo -> Octo Canyon\n* Octo Canyon: +z -d\n",
+			ids:  map[string]string{"Octo Canyon": "t1", "Octo Expansion": ""},
+		},
+	}
+	for _, tt := range tests {
+		d := DiffTeams(tt.existing, tt.imported)
+		if got := d.String(); got != tt.want {
+			t.Errorf("%s: got diff\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+		if d.Empty() != (tt.want == "") {
+			t.Errorf("%s: Empty() = %v", tt.name, d.Empty())
+		}
+
+		ids := make(map[string]string)
+		for _, team := range d.Added {
+			ids[team.Name] = team.ID
+		}
+		for _, team := range d.Removed {
+			ids[team.Name] = team.ID
+		}
+		for _, r := range d.Renamed {
+			ids[r.To.Name] = r.To.ID
+		}
+		for _, c := range d.Changed {
+			ids[c.Team.Name] = c.Team.ID
+		}
+		for name, id := range tt.ids {
+			if got, ok := ids[name]; !ok || got != id {
+				t.Errorf("%s: %s has ID %q, want %q", tt.name, name, got, id)
+			}
+		}
+	}
+}
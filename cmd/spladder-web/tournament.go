@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+	_ "time/tzdata"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Tournament holds the settings stored on the tournament document.
+type Tournament struct {
+	// Name is the label of the tournament shown in listings, such as "Spladder#8". Defaults to the document ID.
+	Name         string `firestore:"name,omitempty"`
+	CurrentRound Round  `firestore:"currentRound"`
+	TeamCount    int    `firestore:"teamCount"`
+	// RosterLockDate is the time after which no players may join a team.
+	RosterLockDate time.Time `firestore:"rosterLockDate,omitempty"`
+	// MaxSubstitutions limits how many times a team may field a substitute during the tournament.
+	// Zero disables the limit.
+	MaxSubstitutions int `firestore:"maxSubstitutions"`
+	// ForfeitPolicy decides what happens to unplayed challenges once the round deadline passes:
+	// "double" forfeits both teams, "challenger" or "defender" forfeits that side, anything else only flags them.
+	ForfeitPolicy string `firestore:"forfeitPolicy,omitempty"`
+	// DeadlineWarningHours is how long before the deadline unplayed challenges are flagged. Defaults to 24.
+	DeadlineWarningHours int `firestore:"deadlineWarningHours,omitempty"`
+	// MatchMinutes is the time set aside for a match when suggesting match times. Defaults to 60.
+	MatchMinutes int `firestore:"matchMinutes,omitempty"`
+	// Timezone is the IANA time zone in which match times are entered and displayed. Defaults to Asia/Tokyo.
+	Timezone string `firestore:"timezone,omitempty"`
+	// SlackWebhookEnv and SlackTokenEnv name the environment variables holding the Slack secrets,
+	// which default to SLACK_WEBHOOK_URL and SLACK_BOT_TOKEN.
+	SlackWebhookEnv string `firestore:"slackWebhookEnv,omitempty"`
+	SlackTokenEnv   string `firestore:"slackTokenEnv,omitempty"`
+	SlackChannel    string `firestore:"slackChannel,omitempty"`
+	// WinsRequired is the number of games a team must win to take a challenge. Defaults to 4.
+	WinsRequired int `firestore:"winsRequired,omitempty"`
+	// AnnounceTopN is how many ranks are listed when announcing round results. Defaults to 10.
+	AnnounceTopN int `firestore:"announceTopN,omitempty"`
+}
+
+// LoadTournament reads the settings of the tournament.
+func LoadTournament(ctx context.Context, tournament *firestore.DocumentRef) (Tournament, error) {
+	var t Tournament
+	doc, err := tournament.Get(ctx)
+	if err != nil {
+		return t, err
+	}
+	err = doc.DataTo(&t)
+	return t, err
+}
+
+// Label returns the name of the tournament stored under id.
+func (t Tournament) Label(id string) string {
+	if t.Name == "" {
+		return id
+	}
+	return t.Name
+}
+
+// Location returns the time zone of the tournament.
+func (t Tournament) Location() (*time.Location, error) {
+	if t.Timezone == "" {
+		return time.LoadLocation("Asia/Tokyo")
+	}
+	return time.LoadLocation(t.Timezone)
+}
+
+// Wins returns the number of games a team must win to take a challenge.
+func (t Tournament) Wins() int {
+	if t.WinsRequired == 0 {
+		return 4
+	}
+	return t.WinsRequired
+}
+
+// ValidScore reports whether a score is a finished challenge under the tournament's match format.
+func (t Tournament) ValidScore(challengerScore, defenderScore int) bool {
+	wins := t.Wins()
+	if challengerScore < 0 || defenderScore < 0 {
+		return false
+	}
+	return (challengerScore == wins && defenderScore < wins) || (defenderScore == wins && challengerScore < wins)
+}
+
+// RosterLocked reports whether the roster lock date has passed.
+func (t Tournament) RosterLocked(now time.Time) bool {
+	return !t.RosterLockDate.IsZero() && now.After(t.RosterLockDate)
+}
@@ -0,0 +1,60 @@
+package announce
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/knagayama/ladder-firebase/ladder"
+	"google.golang.org/api/iterator"
+)
+
+// ServeCalendar serves the scheduled challenges of the tournament as an iCalendar feed.
+// The team query parameter, given as a team ID or name, limits the feed to that team's challenges.
+func ServeCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tournament := tournamentRef()
+	settings, err := loadTournament(ctx, tournament)
+	if err != nil {
+		log.Printf("Error reading tournament: %s", err)
+		http.Error(w, "tournament not found", http.StatusInternalServerError)
+		return
+	}
+	duration := time.Duration(settings.MatchMinutes) * time.Minute
+	if duration == 0 {
+		duration = time.Hour
+	}
+	team := r.URL.Query().Get("team")
+
+	events := make([]ladder.Event, 0)
+	iter := tournament.Collection("challenges").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.Printf("Error reading challenges: %s", err)
+			http.Error(w, "could not read challenges", http.StatusInternalServerError)
+			return
+		}
+		var c Challenge
+		if err = doc.DataTo(&c); err != nil {
+			log.Printf("Error decoding challenge %s: %s", doc.Ref.ID, err)
+			continue
+		}
+		if team != "" && team != c.ChallengerID && team != c.DefenderID && team != c.Challenger && team != c.Defender {
+			continue
+		}
+		events = append(events, ladder.Event{ID: doc.Ref.ID, Match: c.Match()})
+	}
+
+	name := tournament.ID
+	if team != "" {
+		name += " " + team
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err = ladder.WriteCalendar(w, tournament.ID, name, events, duration, settings.location()); err != nil {
+		log.Printf("Error writing calendar: %s", err)
+	}
+}
@@ -0,0 +1,144 @@
+package announce
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Challenge holds data for a challenge, as written by the spladder-web command.
+type Challenge = ladder.Challenge
+
+// Stream is a stream of a challenge from one player's perspective, live or as a VOD.
+type Stream = ladder.Stream
+
+// report returns the score reported on the challenge through Slack.
+func report(c Challenge) render.Report {
+	return render.Report{
+		Match:           c.Match(),
+		ChallengerScore: c.ReportedChallengerScore,
+		DefenderScore:   c.ReportedDefenderScore,
+		ReportedBy:      c.ReportedBy,
+	}
+}
+
+// matches returns the challenges as they are presented to players.
+func matches(challenges []Challenge) []ladder.Match {
+	ms := make([]ladder.Match, 0, len(challenges))
+	for _, c := range challenges {
+		ms = append(ms, c.Match())
+	}
+	return ms
+}
+
+// Tournament holds the settings stored on the tournament document.
+type Tournament struct {
+	CurrentRound int64 `firestore:"currentRound"`
+	// ForfeitPolicy is applied by CheckRoundDeadlines; see forfeitUpdates.
+	ForfeitPolicy        string `firestore:"forfeitPolicy"`
+	DeadlineWarningHours int64  `firestore:"deadlineWarningHours"`
+	MatchMinutes         int64  `firestore:"matchMinutes"`
+	// Timezone is the IANA time zone used for announcement windows and displayed times.
+	Timezone string `firestore:"timezone"`
+	// SlackWebhookEnv and SlackTokenEnv name the environment variables holding the Slack secrets.
+	SlackWebhookEnv string `firestore:"slackWebhookEnv"`
+	SlackTokenEnv   string `firestore:"slackTokenEnv"`
+	SlackChannel    string `firestore:"slackChannel"`
+	// SlackSigningSecretEnv names the environment variable holding the signing secret of the Slack app,
+	// which defaults to SLACK_SIGNING_SECRET.
+	SlackSigningSecretEnv string `firestore:"slackSigningSecretEnv"`
+	// OrganiserChannel is where disputed scores are reported. Defaults to the tournament's channel.
+	OrganiserChannel string `firestore:"organiserChannel"`
+	// AnnounceWindowHours is how far ahead the daily announcement lists matches. Defaults to 24.
+	AnnounceWindowHours int64 `firestore:"announceWindowHours"`
+	// ReminderHours and ReminderMinutes are how long before a scheduled challenge its teams are reminded.
+	// They default to 24 hours and 30 minutes.
+	ReminderHours   int64 `firestore:"reminderHours"`
+	ReminderMinutes int64 `firestore:"reminderMinutes"`
+	// WinsRequired is the number of games a team must win to take a challenge. Defaults to 4.
+	WinsRequired int64 `firestore:"winsRequired"`
+	// StandingsURL is the page linked from announcements for the current standings.
+	StandingsURL string `firestore:"standingsURL"`
+}
+
+// location returns the tournament's time zone, defaulting to Asia/Tokyo.
+func (t Tournament) location() *time.Location {
+	name := t.Timezone
+	if name == "" {
+		name = "Asia/Tokyo"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Unknown timezone %q, using Asia/Tokyo: %s", name, err)
+		return time.FixedZone("Asia/Tokyo", 9*60*60)
+	}
+	return loc
+}
+
+// wins returns the number of games a team must win to take a challenge.
+func (t Tournament) wins() int64 {
+	if t.WinsRequired == 0 {
+		return 4
+	}
+	return t.WinsRequired
+}
+
+// Round holds the period in which the challenges of a round must be played.
+// End is also the deadline for reporting results.
+type Round = ladder.Window
+
+// tournamentFromName returns the tournament a Firestore document belongs to, given the document's resource name
+// such as "projects/p/databases/(default)/documents/tournaments/spladder5/challenges/3-2".
+func tournamentFromName(name string) *firestore.DocumentRef {
+	parts := strings.Split(name, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "tournaments" {
+			return firestoreClient().Collection("tournaments").Doc(parts[i+1])
+		}
+	}
+	return tournamentRef()
+}
+
+// tournamentRef returns the tournament the functions operate on, given by the TOURNAMENT_ID environment variable.
+func tournamentRef() *firestore.DocumentRef {
+	id := os.Getenv("TOURNAMENT_ID")
+	if id == "" {
+		id = "spladder5"
+	}
+	return firestoreClient().Collection("tournaments").Doc(id)
+}
+
+// loadTournament reads the settings of the tournament.
+func loadTournament(ctx context.Context, tournament *firestore.DocumentRef) (Tournament, error) {
+	var t Tournament
+	doc, err := tournament.Get(ctx)
+	if err != nil {
+		return t, err
+	}
+	err = doc.DataTo(&t)
+	return t, err
+}
+
+// loadRound reads the window of a round. A round without a window yields an empty window.
+func loadRound(ctx context.Context, tournament *firestore.DocumentRef, round int64) (Round, error) {
+	var r Round
+	doc, err := tournament.Collection("rounds").Doc(strconv.FormatInt(round, 10)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	err = doc.DataTo(&r)
+	return r, err
+}
@@ -0,0 +1,328 @@
+package announce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+	"github.com/slack-go/slack"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errDuplicateStream is returned when a stream is already listed on a challenge.
+var errDuplicateStream = errors.New("stream already added")
+
+const commandUsage = "使い方:\n" +
+	"`/ladder score <試合番号> <チャレンジャーのスコア>-<ディフェンダーのスコア>` 結果を報告 (例: `/ladder score 3-2 4-1`)\n" +
+	"`/ladder standings` 現在のランキング\n" +
+	"`/ladder team <チーム名>` チームの順位推移・戦績・次の試合\n" +
+	"`/ladder matches [チーム名|all]` 現在のラウンドの自チーム (チーム名で指定したチーム、all で全チーム) の未消化の試合\n" +
+	"`/ladder schedule <試合番号> <日時>` 試合日時を提案・承認 (例: `/ladder schedule 3-2 2022-05-14T21:00`)\n" +
+	"`/ladder stream <試合番号> <URL> [vod]` 配信URLを登録 (アーカイブは vod を付ける)\n" +
+	"`/ladder link <ハンドル>` Slack ユーザーとプレイヤーのリンクを申請 (運営の承認が必要)"
+
+// signingSecret returns the signing secret of the Slack app from the environment variable the tournament names.
+func (t Tournament) signingSecret() string {
+	env := t.SlackSigningSecretEnv
+	if env == "" {
+		env = "SLACK_SIGNING_SECRET"
+	}
+	return os.Getenv(env)
+}
+
+// verifySlackRequest checks the signature of a request sent by Slack and restores its body for parsing.
+func verifySlackRequest(r *http.Request, secret string) error {
+	if secret == "" {
+		return fmt.Errorf("no Slack signing secret configured")
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	verifier, err := slack.NewSecretsVerifier(r.Header, secret)
+	if err != nil {
+		return err
+	}
+	if _, err = verifier.Write(body); err != nil {
+		return err
+	}
+	return verifier.Ensure()
+}
+
+func ephemeral(text string) *slack.Msg {
+	return &slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: text}
+}
+
+func inChannel(text string) *slack.Msg {
+	return &slack.Msg{ResponseType: slack.ResponseTypeInChannel, Text: text}
+}
+
+// HandleSlashCommand handles the /ladder slash command of the tournament's Slack app.
+func HandleSlashCommand(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tournament := tournamentRef()
+	settings, err := loadTournament(ctx, tournament)
+	if err != nil {
+		log.Printf("Error reading tournament: %s", err)
+		http.Error(w, "tournament not found", http.StatusInternalServerError)
+		return
+	}
+	if err = verifySlackRequest(r, settings.signingSecret()); err != nil {
+		log.Printf("Rejected slash command: %s", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	cmd, err := slack.SlashCommandParse(r)
+	if err != nil {
+		http.Error(w, "invalid slash command", http.StatusBadRequest)
+		return
+	}
+
+	args := strings.Fields(cmd.Text)
+	var reply *slack.Msg
+	if len(args) == 0 {
+		reply = ephemeral(commandUsage)
+	} else {
+		switch args[0] {
+		case "score":
+			reply, err = reportScore(ctx, tournament, settings, cmd, args[1:])
+		case "standings":
+			reply, err = showStandings(ctx, tournament, settings)
+		case "team":
+			if len(args) < 2 {
+				reply = ephemeral(commandUsage)
+				break
+			}
+			reply, err = showTeam(ctx, tournament, settings, strings.Join(args[1:], " "))
+		case "matches":
+			reply, err = showMatches(ctx, tournament, settings, cmd.UserID, strings.Join(args[1:], " "))
+		case "schedule":
+			reply, err = scheduleMatch(ctx, tournament, settings, cmd, args[1:])
+		case "stream":
+			reply, err = submitStream(ctx, tournament, settings, cmd, args[1:])
+		case "link":
+			reply, err = linkPlayer(ctx, settings, cmd, args[1:])
+		default:
+			reply = ephemeral(commandUsage)
+		}
+	}
+	if err != nil {
+		log.Printf("Error handling %q from %s: %s", cmd.Text, cmd.UserID, err)
+		reply = ephemeral("エラーが発生しました。運営に連絡してください。")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(reply); err != nil {
+		log.Printf("Error writing reply: %s", err)
+	}
+}
+
+// parseScore parses a score such as "4-1", given as the challenger's score followed by the defender's.
+func parseScore(s string) (int64, int64, error) {
+	c, d, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid score %q", s)
+	}
+	cs, err := strconv.ParseInt(c, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid score %q", s)
+	}
+	ds, err := strconv.ParseInt(d, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid score %q", s)
+	}
+	return cs, ds, nil
+}
+
+// validScore reports whether a score is a finished challenge under the match format.
+func validScore(cs, ds, wins int64) bool {
+	if cs < 0 || ds < 0 {
+		return false
+	}
+	return (cs == wins && ds < wins) || (ds == wins && cs < wins)
+}
+
+// loadCurrentChallenge reads a challenge of the current round by its key, e.g. "3-2".
+// It returns a reply explaining the problem when there is no such challenge.
+func loadCurrentChallenge(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, key string) (*firestore.DocumentRef, Challenge, *slack.Msg, error) {
+	var c Challenge
+	ref := tournament.Collection("challenges").Doc(key)
+	doc, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return ref, c, ephemeral(fmt.Sprintf("試合 %s が見つかりません。", key)), nil
+	}
+	if err != nil {
+		return ref, c, nil, err
+	}
+	if err = doc.DataTo(&c); err != nil {
+		return ref, c, nil, err
+	}
+	if int64(c.Round) != settings.CurrentRound {
+		return ref, c, ephemeral(fmt.Sprintf("試合 %s は現在のラウンドの試合ではありません。", key)), nil
+	}
+	return ref, c, nil, nil
+}
+
+// reportScore records the score of a challenge of the current round, e.g. "/ladder score 3-2 4-1".
+func reportScore(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, cmd slack.SlashCommand, args []string) (*slack.Msg, error) {
+	if len(args) != 2 {
+		return ephemeral(commandUsage), nil
+	}
+	key := args[0]
+	cs, ds, err := parseScore(args[1])
+	if err != nil {
+		return ephemeral(commandUsage), nil
+	}
+	if !validScore(cs, ds, settings.wins()) {
+		return ephemeral(fmt.Sprintf("%d-%d は無効なスコアです。%d本先取で入力してください。", cs, ds, settings.wins())), nil
+	}
+
+	ref, c, reply, err := loadCurrentChallenge(ctx, tournament, settings, key)
+	if err != nil || reply != nil {
+		return reply, err
+	}
+	_, teamID, reply, err := authorizeChallenge(ctx, tournament, cmd.UserID, key, c)
+	if err != nil || reply != nil {
+		return reply, err
+	}
+	if c.Played() {
+		return ephemeral(fmt.Sprintf("試合 %s の結果は既に登録されています。", key)), nil
+	}
+	if c.ReportStatus == ladder.ReportPending {
+		return ephemeral(fmt.Sprintf("試合 %s の結果は既に報告され、相手チームの確認待ちです。", key)), nil
+	}
+
+	// The score only counts once the other team confirms it; see HandleInteraction.
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "ReportStatus", Value: ladder.ReportPending},
+		{Path: "ReportedChallengerScore", Value: cs},
+		{Path: "ReportedDefenderScore", Value: ds},
+		{Path: "ReportedBy", Value: cmd.UserID},
+		{Path: "ReportedTeam", Value: teamID},
+		{Path: "ReportedAt", Value: time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.ReportedChallengerScore = int(cs)
+	c.ReportedDefenderScore = int(ds)
+	c.ReportedBy = cmd.UserID
+	message := render.ScoreReport(key, report(c))
+	reply = inChannel(message.Text)
+	reply.Blocks = slack.Blocks{BlockSet: message.Blocks}
+	return reply, nil
+}
+
+// scheduleMatch proposes a match time on behalf of the user's team, e.g. "/ladder schedule 3-2 2022-05-14T21:00".
+// Proposing the time the other team proposed confirms it.
+func scheduleMatch(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, cmd slack.SlashCommand, args []string) (*slack.Msg, error) {
+	if len(args) != 2 {
+		return ephemeral(commandUsage), nil
+	}
+	key := args[0]
+	loc := settings.location()
+	t, err := time.ParseInLocation("2006-01-02T15:04", args[1], loc)
+	if err != nil {
+		return ephemeral(commandUsage), nil
+	}
+	ref, c, reply, err := loadCurrentChallenge(ctx, tournament, settings, key)
+	if err != nil || reply != nil {
+		return reply, err
+	}
+	_, teamID, reply, err := authorizeChallenge(ctx, tournament, cmd.UserID, key, c)
+	if err != nil || reply != nil {
+		return reply, err
+	}
+	round, err := loadRound(ctx, tournament, int64(c.Round))
+	if err != nil {
+		return nil, err
+	}
+	if !round.Contains(t) {
+		return ephemeral(fmt.Sprintf("%s は Round %d の期間 (%s - %s) 外です。", t.Format("2006-01-02 15:04"), c.Round,
+			round.Start.In(loc).Format("2006-01-02 15:04"), round.End.In(loc).Format("2006-01-02 15:04"))), nil
+	}
+
+	if c.ProposedDate.Equal(t) && c.ProposedBy != "" && c.ProposedBy != teamID {
+		_, err = ref.Update(ctx, []firestore.Update{
+			{Path: "Date", Value: t},
+			{Path: "ProposedDate", Value: firestore.Delete},
+			{Path: "ProposedBy", Value: firestore.Delete},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return inChannel(fmt.Sprintf("<@%s> が日程を承認しました: %s %s", cmd.UserID, t.Format("2006-01-02 15:04"), render.Title(c.Match()))), nil
+	}
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "ProposedDate", Value: t},
+		{Path: "ProposedBy", Value: teamID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inChannel(fmt.Sprintf("<@%s> が日程を提案しました: %s %s\n相手チームの方は同じコマンドで承認してください。",
+		cmd.UserID, t.Format("2006-01-02 15:04"), render.Title(c.Match()))), nil
+}
+
+// submitStream adds a stream to a challenge, e.g. "/ladder stream 3-2 https://www.twitch.tv/..."
+// or "/ladder stream 3-2 https://youtu.be/... vod" for a recording. The stream is announced by SendURLToSlack.
+func submitStream(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, cmd slack.SlashCommand, args []string) (*slack.Msg, error) {
+	if len(args) != 2 && !(len(args) == 3 && args[2] == "vod") {
+		return ephemeral(commandUsage), nil
+	}
+	key := args[0]
+	ref, c, reply, err := loadCurrentChallenge(ctx, tournament, settings, key)
+	if err != nil || reply != nil {
+		return reply, err
+	}
+	p, _, reply, err := authorizeChallenge(ctx, tournament, cmd.UserID, key, c)
+	if err != nil || reply != nil {
+		return reply, err
+	}
+	platform, streamURL, err := normalizeStreamURL(args[1])
+	if err != nil {
+		return ephemeral(fmt.Sprintf("配信URLを登録できません: %s\nYouTube、Twitch、ニコニコ、X (Twitter) の配信・動画のURLを指定してください。", err)), nil
+	}
+	stream := Stream{
+		Streamer: p.name(),
+		Platform: platform,
+		URL:      streamURL,
+		VOD:      len(args) == 3,
+		AddedAt:  time.Now(),
+	}
+
+	err = firestoreClient().RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var current Challenge
+		if err = doc.DataTo(&current); err != nil {
+			return err
+		}
+		if current.HasStream(stream.URL) {
+			return errDuplicateStream
+		}
+		return tx.Update(ref, []firestore.Update{{Path: "Streams", Value: append(current.Streams, stream)}})
+	})
+	if err == errDuplicateStream {
+		return ephemeral(fmt.Sprintf("この配信は試合 %s に登録済みです。", key)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ephemeral(fmt.Sprintf("試合 %s の配信URLを登録しました。", key)), nil
+}
@@ -0,0 +1,152 @@
+package announce
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+	"google.golang.org/api/iterator"
+)
+
+// forfeitUpdates returns the fields to write when a challenge is forfeited under the given policy,
+// where the winning side is awarded wins games. It returns false when the policy does not forfeit challenges.
+func forfeitUpdates(policy string, wins int64) ([]firestore.Update, bool) {
+	switch policy {
+	case "double":
+		return []firestore.Update{
+			{Path: "Forfeit", Value: "double"},
+			{Path: "Flag", Value: firestore.Delete},
+		}, true
+	case "challenger":
+		return []firestore.Update{
+			{Path: "Forfeit", Value: "challenger"},
+			{Path: "ChallengerScore", Value: 0},
+			{Path: "DefenderScore", Value: wins},
+			{Path: "Flag", Value: firestore.Delete},
+		}, true
+	case "defender":
+		return []firestore.Update{
+			{Path: "Forfeit", Value: "defender"},
+			{Path: "ChallengerScore", Value: wins},
+			{Path: "DefenderScore", Value: 0},
+			{Path: "Flag", Value: firestore.Delete},
+		}, true
+	}
+	return nil, false
+}
+
+// CheckRoundDeadlines flags unscheduled or unplayed challenges as the deadline of the current round approaches,
+// and applies the tournament's forfeit policy once it has passed. It is meant to run from Cloud Scheduler.
+func CheckRoundDeadlines(ctx context.Context, m PubSubMessage) error {
+	tournament := tournamentRef()
+	settings, err := loadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	round, err := loadRound(ctx, tournament, settings.CurrentRound)
+	if err != nil {
+		return err
+	}
+	if round.End.IsZero() {
+		return nil
+	}
+	warning := time.Duration(settings.DeadlineWarningHours) * time.Hour
+	if warning == 0 {
+		warning = 24 * time.Hour
+	}
+	now := time.Now()
+	if now.Before(round.End.Add(-warning)) {
+		return nil
+	}
+	passed := now.After(round.End)
+
+	iter := tournament.Collection("challenges").Where("Round", "==", settings.CurrentRound).OrderBy(
+		"Code", firestore.Asc).Documents(ctx)
+	var flagged, forfeited []string
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var c Challenge
+		if err = doc.DataTo(&c); err != nil {
+			return err
+		}
+		// Disputed results are already flagged and left to organisers.
+		if c.Played() || c.ReportStatus == ladder.ReportDisputed {
+			continue
+		}
+		text := fmt.Sprintf("[%d-%d] Div %s: %s (%d位) vs %s (%d位)", c.Round, c.Code, c.Division.String(),
+			c.Challenger, c.ChallengerRank, c.Defender, c.DefenderRank)
+
+		// A result awaiting confirmation is not forfeited.
+		if passed && c.ReportStatus != ladder.ReportPending {
+			if updates, ok := forfeitUpdates(settings.ForfeitPolicy, settings.wins()); ok {
+				if _, err = doc.Ref.Update(ctx, updates); err != nil {
+					return err
+				}
+				forfeited = append(forfeited, text)
+				continue
+			}
+		}
+
+		flag := "unplayed"
+		if c.Date.IsZero() {
+			flag = "unscheduled"
+		}
+		if passed {
+			flag = "expired"
+		}
+		if c.ReportStatus == ladder.ReportPending {
+			flag = "unconfirmed"
+		}
+		if c.Flag == flag {
+			continue
+		}
+		if _, err = doc.Ref.Update(ctx, []firestore.Update{{Path: "Flag", Value: flag}}); err != nil {
+			return err
+		}
+		switch flag {
+		case "unscheduled":
+			text += " 日程未定"
+		case "unplayed":
+			text += " 未消化"
+		case "expired":
+			text += " 締切超過"
+		case "unconfirmed":
+			text += " 結果確認待ち"
+		}
+		flagged = append(flagged, text)
+	}
+
+	deadline := round.End.In(settings.location()).Format("2006-01-02 15:04")
+	message := ""
+	if len(flagged) > 0 {
+		header := "<!channel> Round %d の締切 (%s) が迫っています。以下の試合を確認してください！\n"
+		if passed {
+			header = "<!channel> Round %d の締切 (%s) を過ぎました。以下の試合は運営が確認します。\n"
+		}
+		message += fmt.Sprintf(header, settings.CurrentRound, deadline)
+		for _, text := range flagged {
+			message += text + "\n"
+		}
+	}
+	if len(forfeited) > 0 {
+		message += fmt.Sprintf("Round %d の締切 (%s) を過ぎたため、以下の試合は不戦敗になりました。\n", settings.CurrentRound, deadline)
+		for _, text := range forfeited {
+			message += text + "\n"
+		}
+	}
+	if message == "" {
+		return nil
+	}
+	log.Print(message)
+	return postToSlack(settings.slackConfig(), render.Text(message))
+}
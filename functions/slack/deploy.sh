@@ -0,0 +1,25 @@
+#!/bin/sh
+# Deploys a function of this module with gcloud.
+#
+# The module depends on the shared ladder module through a replace directive pointing at ../../ladder,
+# which is outside the uploaded source, so the source is staged with a copy of ladder and the
+# directive pointed at it.
+#
+# Usage: ./deploy.sh FUNCTION [gcloud flags...]
+# e.g.   ./deploy.sh SendMatchesToSlack --runtime go119 --trigger-topic daily-digest
+set -eu
+
+if [ $# -lt 1 ]; then
+	echo "usage: $0 FUNCTION [gcloud flags...]" >&2
+	exit 2
+fi
+
+cd "$(dirname "$0")"
+stage=$(mktemp -d)
+trap 'rm -rf "$stage"' EXIT
+
+cp ./*.go go.mod go.sum "$stage"
+cp -R ../../ladder "$stage/ladder"
+(cd "$stage" && go mod edit -replace github.com/knagayama/ladder-firebase/ladder=./ladder)
+
+gcloud functions deploy "$@" --source "$stage"
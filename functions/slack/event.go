@@ -0,0 +1,229 @@
+package announce
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FirestoreEvent is the payload of a Firestore trigger.
+// OldValue is empty when a document is created, and Value is empty when it is deleted.
+type FirestoreEvent struct {
+	OldValue   FirestoreValue `json:"oldValue"`
+	Value      FirestoreValue `json:"value"`
+	UpdateMask struct {
+		FieldPaths []string `json:"fieldPaths"`
+	} `json:"updateMask"`
+}
+
+// FirestoreValue is a document in a Firestore event, with its fields in the Firestore REST encoding.
+type FirestoreValue struct {
+	CreateTime time.Time              `json:"createTime"`
+	Fields     map[string]interface{} `json:"fields"`
+	Name       string                 `json:"name"`
+	UpdateTime time.Time              `json:"updateTime"`
+}
+
+// Exists reports whether the value holds a document.
+func (v FirestoreValue) Exists() bool {
+	return v.Name != ""
+}
+
+// DataTo decodes the fields of the document into the struct pointed to by p, as DocumentSnapshot.DataTo does.
+// Struct fields are matched by the name in their firestore tag, or by their Go name when they have none,
+// and fields tagged "-" are skipped. Fields missing from the document are left untouched.
+func (v FirestoreValue) DataTo(p interface{}) error {
+	dst := reflect.ValueOf(p)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() || dst.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %T, a pointer to a struct is required", p)
+	}
+	fields := make(map[string]interface{}, len(v.Fields))
+	for name, value := range v.Fields {
+		plain, err := plainValue(value)
+		if err != nil {
+			return fmt.Errorf("field %s: %s", name, err)
+		}
+		fields[name] = plain
+	}
+	return setFields(dst.Elem(), fields)
+}
+
+// fieldName returns the Firestore name of a struct field, or an empty string when the field is skipped.
+func fieldName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("firestore"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+// setFields sets the fields of the struct dst from the plain values in fields.
+func setFields(dst reflect.Value, fields map[string]interface{}) error {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := fieldName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if err := setValue(dst.Field(i), value); err != nil {
+			return fmt.Errorf("field %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// setValue sets dst from a plain value returned by plainValue.
+func setValue(dst reflect.Value, value interface{}) error {
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Type() == reflect.TypeOf(time.Time{}) {
+		t, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into a time", value)
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			dst.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			dst.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := value.(int64); ok {
+			if dst.OverflowInt(n) {
+				return fmt.Errorf("%d overflows %s", n, dst.Type())
+			}
+			dst.SetInt(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := value.(type) {
+		case float64:
+			dst.SetFloat(n)
+			return nil
+		case int64:
+			dst.SetFloat(float64(n))
+			return nil
+		}
+	case reflect.Slice:
+		if values, ok := value.([]interface{}); ok {
+			slice := reflect.MakeSlice(dst.Type(), len(values), len(values))
+			for i, element := range values {
+				if err := setValue(slice.Index(i), element); err != nil {
+					return err
+				}
+			}
+			dst.Set(slice)
+			return nil
+		}
+	case reflect.Map:
+		if m, ok := value.(map[string]interface{}); ok && dst.Type().Key().Kind() == reflect.String {
+			out := reflect.MakeMapWithSize(dst.Type(), len(m))
+			for key, element := range m {
+				e := reflect.New(dst.Type().Elem()).Elem()
+				if err := setValue(e, element); err != nil {
+					return err
+				}
+				out.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), e)
+			}
+			dst.Set(out)
+			return nil
+		}
+	case reflect.Struct:
+		if m, ok := value.(map[string]interface{}); ok {
+			return setFields(dst, m)
+		}
+	case reflect.Ptr:
+		e := reflect.New(dst.Type().Elem())
+		if err := setValue(e.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(e)
+		return nil
+	case reflect.Interface:
+		if dst.NumMethod() == 0 {
+			dst.Set(reflect.ValueOf(value))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot decode %T into %s", value, dst.Type())
+}
+
+// plainValue converts a value in the Firestore REST encoding, such as {"integerValue": "3"},
+// into the Go value it holds: nil, a bool, an int64, a float64, a string, a time.Time,
+// or a []interface{} or map[string]interface{} of those.
+// References, bytes and geo points are kept in their REST encoding.
+func plainValue(value interface{}) (interface{}, error) {
+	typed, ok := value.(map[string]interface{})
+	if !ok || len(typed) != 1 {
+		return nil, fmt.Errorf("unexpected value %v", value)
+	}
+	for kind, v := range typed {
+		switch kind {
+		case "nullValue":
+			return nil, nil
+		case "booleanValue", "doubleValue", "stringValue", "referenceValue", "bytesValue", "geoPointValue":
+			return v, nil
+		case "integerValue":
+			// Integers are encoded as strings to keep their precision.
+			switch n := v.(type) {
+			case string:
+				return strconv.ParseInt(n, 10, 64)
+			case float64:
+				return int64(n), nil
+			}
+			return nil, fmt.Errorf("unexpected integer %v", v)
+		case "timestampValue":
+			s, _ := v.(string)
+			return time.Parse(time.RFC3339Nano, s)
+		case "arrayValue":
+			array, _ := v.(map[string]interface{})
+			values, _ := array["values"].([]interface{})
+			plain := make([]interface{}, 0, len(values))
+			for _, element := range values {
+				p, err := plainValue(element)
+				if err != nil {
+					return nil, err
+				}
+				plain = append(plain, p)
+			}
+			return plain, nil
+		case "mapValue":
+			m, _ := v.(map[string]interface{})
+			fields, _ := m["fields"].(map[string]interface{})
+			plain := make(map[string]interface{}, len(fields))
+			for name, field := range fields {
+				p, err := plainValue(field)
+				if err != nil {
+					return nil, err
+				}
+				plain[name] = p
+			}
+			return plain, nil
+		default:
+			return nil, fmt.Errorf("unknown value type %s", kind)
+		}
+	}
+	return nil, nil
+}
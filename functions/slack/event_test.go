@@ -0,0 +1,136 @@
+package announce
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/knagayama/ladder-firebase/ladder"
+)
+
+// restValue decodes a value in the Firestore REST encoding from JSON, as it arrives in an event.
+func restValue(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestPlainValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  interface{}
+	}{
+		{`{"nullValue": null}`, nil},
+		{`{"booleanValue": true}`, true},
+		{`{"integerValue": "9007199254740993"}`, int64(9007199254740993)},
+		{`{"integerValue": 3}`, int64(3)},
+		{`{"doubleValue": 1.5}`, 1.5},
+		{`{"stringValue": "イカ"}`, "イカ"},
+		{`{"timestampValue": "2022-05-14T12:00:00.5Z"}`, time.Date(2022, 5, 14, 12, 0, 0, 5e8, time.UTC)},
+		{`{"arrayValue": {}}`, []interface{}{}},
+		{`{"arrayValue": {"values": [{"stringValue": "a"}, {"integerValue": "1"}]}}`, []interface{}{"a", int64(1)}},
+		{`{"mapValue": {"fields": {"URL": {"stringValue": "u"}}}}`, map[string]interface{}{"URL": "u"}},
+	}
+	for _, tt := range tests {
+		got, err := plainValue(restValue(t, tt.value))
+		if err != nil {
+			t.Errorf("plainValue(%s): %s", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("plainValue(%s) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{
+		`"bare"`,
+		`{"integerValue": "3.5"}`,
+		`{"timestampValue": "yesterday"}`,
+		`{"unknownValue": 1}`,
+		`{"stringValue": "a", "integerValue": "1"}`,
+	} {
+		if got, err := plainValue(restValue(t, value)); err == nil {
+			t.Errorf("plainValue(%s) = %#v, want an error", value, got)
+		}
+	}
+}
+
+func TestDataTo(t *testing.T) {
+	fields := restValue(t, `{
+		"Round": {"integerValue": "3"},
+		"Code": {"integerValue": "2"},
+		"Challenger": {"stringValue": "イカ研究所"},
+		"ChallengerRank": {"integerValue": "5"},
+		"Division": {"integerValue": "1"},
+		"Date": {"timestampValue": "2022-05-14T12:00:00Z"},
+		"ChallengerLineup": {"arrayValue": {"values": [{"stringValue": "p1"}, {"stringValue": "p2"}]}},
+		"Streams": {"arrayValue": {"values": [{"mapValue": {"fields": {
+			"Streamer": {"stringValue": "hime"},
+			"URL": {"stringValue": "https://www.twitch.tv/hime"},
+			"VOD": {"booleanValue": true}
+		}}}]}},
+		"Forfeit": {"nullValue": null},
+		"Unknown": {"stringValue": "ignored"}
+	}`).(map[string]interface{})
+	v := FirestoreValue{Name: "projects/p/databases/(default)/documents/tournaments/t/challenges/3-2", Fields: fields}
+
+	c := Challenge{Forfeit: "double", Flag: "expired"}
+	if err := v.DataTo(&c); err != nil {
+		t.Fatal(err)
+	}
+	want := Challenge{
+		Round:            3,
+		Code:             2,
+		Challenger:       "イカ研究所",
+		ChallengerRank:   5,
+		Division:         ladder.Division(1),
+		Date:             time.Date(2022, 5, 14, 12, 0, 0, 0, time.UTC),
+		ChallengerLineup: []string{"p1", "p2"},
+		Streams:          []Stream{{Streamer: "hime", URL: "https://www.twitch.tv/hime", VOD: true}},
+		// Forfeit is null in the document and cleared, while Flag is missing and kept.
+		Flag: "expired",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("DataTo decoded %+v, want %+v", c, want)
+	}
+}
+
+func TestDataToTags(t *testing.T) {
+	var settings struct {
+		CurrentRound int64  `firestore:"currentRound"`
+		Timezone     string `firestore:"timezone,omitempty"`
+		Skipped      string `firestore:"-"`
+		Untagged     string
+	}
+	v := FirestoreValue{Fields: restValue(t, `{
+		"currentRound": {"integerValue": "4"},
+		"CurrentRound": {"integerValue": "9"},
+		"timezone": {"stringValue": "Asia/Tokyo"},
+		"-": {"stringValue": "x"},
+		"Skipped": {"stringValue": "x"},
+		"Untagged": {"stringValue": "y"}
+	}`).(map[string]interface{})}
+	if err := v.DataTo(&settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.CurrentRound != 4 || settings.Timezone != "Asia/Tokyo" || settings.Skipped != "" || settings.Untagged != "y" {
+		t.Errorf("DataTo decoded %+v", settings)
+	}
+
+	for _, fields := range []string{
+		`{"currentRound": {"stringValue": "4"}}`,
+		`{"timezone": {"integerValue": "4"}}`,
+	} {
+		v := FirestoreValue{Fields: restValue(t, fields).(map[string]interface{})}
+		if err := v.DataTo(&settings); err == nil {
+			t.Errorf("DataTo(%s) succeeded, want an error", fields)
+		}
+	}
+	if err := v.DataTo(settings); err == nil {
+		t.Error("DataTo into a struct value succeeded, want an error")
+	}
+}
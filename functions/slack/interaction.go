@@ -0,0 +1,157 @@
+package announce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+	"github.com/slack-go/slack"
+)
+
+// errNotPending is returned when a report has already been confirmed, disputed or replaced.
+var errNotPending = errors.New("report is not pending")
+
+// organiserConfig returns where disputes are reported.
+func (t Tournament) organiserConfig() ladder.SlackConfig {
+	cfg := t.slackConfig()
+	if t.OrganiserChannel != "" {
+		cfg.Channel = t.OrganiserChannel
+	}
+	return cfg
+}
+
+// HandleInteraction handles the buttons of the tournament's Slack messages.
+// Confirming a reported score records it as the result of the challenge, which counts towards the ranking
+// once an organiser has entered the lineups; disputing it flags the challenge for organisers.
+func HandleInteraction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tournament := tournamentRef()
+	settings, err := loadTournament(ctx, tournament)
+	if err != nil {
+		log.Printf("Error reading tournament: %s", err)
+		http.Error(w, "tournament not found", http.StatusInternalServerError)
+		return
+	}
+	if err = verifySlackRequest(r, settings.signingSecret()); err != nil {
+		log.Printf("Rejected interaction: %s", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var callback slack.InteractionCallback
+	if err = json.Unmarshal([]byte(r.FormValue("payload")), &callback); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		var reply *slack.WebhookMessage
+		switch action.ActionID {
+		case render.ConfirmScoreAction:
+			reply, err = resolveReport(ctx, tournament, settings, action.Value, callback.User.ID, true)
+		case render.DisputeScoreAction:
+			reply, err = resolveReport(ctx, tournament, settings, action.Value, callback.User.ID, false)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Error handling %s on %s from %s: %s", action.ActionID, action.Value, callback.User.ID, err)
+			reply = &slack.WebhookMessage{
+				ResponseType: slack.ResponseTypeEphemeral,
+				Text:         "エラーが発生しました。運営に連絡してください。",
+			}
+		}
+		if err = slack.PostWebhookContext(ctx, callback.ResponseURL, reply); err != nil {
+			log.Printf("Error responding to %s: %s", action.ActionID, err)
+		}
+	}
+}
+
+// resolveReport confirms or disputes the pending report on a challenge on behalf of a Slack user,
+// and returns the response to the message holding the buttons.
+// Only players of the team that did not report the score may respond to it.
+func resolveReport(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, key string, userID string, confirm bool) (*slack.WebhookMessage, error) {
+	_, teamID, denied, err := teamOfSlackUser(ctx, tournament, userID)
+	if err != nil {
+		return nil, err
+	}
+	if denied != nil {
+		return &slack.WebhookMessage{ResponseType: slack.ResponseTypeEphemeral, Text: denied.Text}, nil
+	}
+
+	ref := tournament.Collection("challenges").Doc(key)
+	var c Challenge
+	allowed := false
+	err = firestoreClient().RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err = doc.DataTo(&c); err != nil {
+			return err
+		}
+		if c.ReportStatus != ladder.ReportPending {
+			return errNotPending
+		}
+		allowed = teamID != c.ReportedTeam && (teamID == c.ChallengerID || teamID == c.DefenderID)
+		if !allowed {
+			return nil
+		}
+		if !confirm {
+			return tx.Update(ref, []firestore.Update{
+				{Path: "ReportStatus", Value: ladder.ReportDisputed},
+				{Path: "Flag", Value: ladder.ReportDisputed},
+			})
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "ChallengerScore", Value: c.ReportedChallengerScore},
+			{Path: "DefenderScore", Value: c.ReportedDefenderScore},
+			{Path: "ReportStatus", Value: ladder.ReportConfirmed},
+			{Path: "ConfirmedBy", Value: userID},
+			{Path: "ConfirmedAt", Value: time.Now()},
+			{Path: "Flag", Value: firestore.Delete},
+		})
+	})
+	if err == errNotPending {
+		return &slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
+			Text:         fmt.Sprintf("試合 %s の報告は既に処理されています。", key),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return &slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
+			Text:         "結果を確認できるのは報告したチームの対戦相手だけです。",
+		}, nil
+	}
+
+	if confirm {
+		return &slack.WebhookMessage{
+			ResponseType:    slack.ResponseTypeInChannel,
+			ReplaceOriginal: true,
+			Text:            fmt.Sprintf("結果が確認されました: %s (報告 <@%s>、確認 <@%s>)\n運営が出場メンバーを登録すると順位に反映されます。", render.ScoreLine(report(c)), c.ReportedBy, userID),
+		}, nil
+	}
+	message := fmt.Sprintf("<!channel> 試合結果に異議がありました: %s (報告 <@%s>、異議 <@%s>)", render.ScoreLine(report(c)), c.ReportedBy, userID)
+	log.Print(message)
+	if err = postToSlack(settings.organiserConfig(), render.Text(message)); err != nil {
+		return nil, err
+	}
+	return &slack.WebhookMessage{
+		ResponseType:    slack.ResponseTypeInChannel,
+		ReplaceOriginal: true,
+		Text:            fmt.Sprintf("結果に異議がありました。運営が確認します: %s", render.ScoreLine(report(c))),
+	}, nil
+}
@@ -0,0 +1,252 @@
+package announce
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+	"github.com/slack-go/slack"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// teamMetrics holds the record of a team for a round, as written when the round is resolved.
+type teamMetrics struct {
+	Round         int64 `firestore:"Round"`
+	Rank          int64 `firestore:"Rank"`
+	NumWins       int64 `firestore:"NumWins"`
+	NumLosses     int64 `firestore:"NumLosses"`
+	NumSetsGained int64 `firestore:"NumSetsGained"`
+	NumSetsLost   int64 `firestore:"NumSetsLost"`
+}
+
+// loadTeamNames maps the IDs of the tournament's teams to their names.
+func loadTeamNames(ctx context.Context, tournament *firestore.DocumentRef) (map[string]string, error) {
+	docs, err := tournament.Collection("teams").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, doc := range docs {
+		if name, ok := doc.Data()["name"].(string); ok {
+			names[doc.Ref.ID] = name
+		}
+	}
+	return names, nil
+}
+
+// rankingFromData reads a ranking document, which maps ranks to team IDs.
+func rankingFromData(data map[string]interface{}) map[int64]string {
+	ranking := make(map[int64]string)
+	for key, value := range data {
+		rank, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		if id, ok := value.(string); ok {
+			ranking[rank] = id
+		}
+	}
+	return ranking
+}
+
+// findTeam returns the ID of a team given its name or ID.
+func findTeam(names map[string]string, name string) (string, bool) {
+	if _, ok := names[name]; ok {
+		return name, true
+	}
+	for id, n := range names {
+		if strings.EqualFold(n, name) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// showStandings replies with the ranking of the current round.
+func showStandings(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament) (*slack.Msg, error) {
+	doc, err := tournament.Collection("ranking").Doc(strconv.FormatInt(settings.CurrentRound, 10)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return ephemeral(fmt.Sprintf("Round %d のランキングはまだありません。", settings.CurrentRound)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names, err := loadTeamNames(ctx, tournament)
+	if err != nil {
+		return nil, err
+	}
+	ranking := rankingFromData(doc.Data())
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Round %d ランキング*\n", settings.CurrentRound)
+	for rank := int64(1); rank <= int64(len(ranking)); rank++ {
+		id, ok := ranking[rank]
+		if !ok {
+			break
+		}
+		fmt.Fprintf(&b, "%d位 %s\n", rank, names[id])
+	}
+	if settings.StandingsURL != "" {
+		b.WriteString(settings.StandingsURL + "\n")
+	}
+	return ephemeral(b.String()), nil
+}
+
+// showTeam replies with the rank history, record and upcoming match of a team.
+func showTeam(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, name string) (*slack.Msg, error) {
+	names, err := loadTeamNames(ctx, tournament)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := findTeam(names, name)
+	if !ok {
+		return ephemeral(fmt.Sprintf("チーム %q が見つかりません。", name)), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n", names[id])
+
+	docs, err := tournament.Collection("ranking").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	type roundRank struct {
+		round, rank int64
+	}
+	history := make([]roundRank, 0)
+	for _, doc := range docs {
+		round, err := strconv.ParseInt(doc.Ref.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		for rank, team := range rankingFromData(doc.Data()) {
+			if team == id {
+				history = append(history, roundRank{round: round, rank: rank})
+			}
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].round < history[j].round })
+	if len(history) > 0 {
+		ranks := make([]string, 0, len(history))
+		for _, h := range history {
+			ranks = append(ranks, fmt.Sprintf("R%d %d位", h.round, h.rank))
+		}
+		b.WriteString("順位推移: " + strings.Join(ranks, " → ") + "\n")
+	}
+
+	docs, err = tournament.Collection("teams").Doc(id).Collection("metrics").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var total teamMetrics
+	for _, doc := range docs {
+		var m teamMetrics
+		if err = doc.DataTo(&m); err != nil {
+			return nil, err
+		}
+		total.NumWins += m.NumWins
+		total.NumLosses += m.NumLosses
+		total.NumSetsGained += m.NumSetsGained
+		total.NumSetsLost += m.NumSetsLost
+	}
+	fmt.Fprintf(&b, "戦績: %d勝%d敗 (取得 %d / 失 %d)\n", total.NumWins, total.NumLosses, total.NumSetsGained, total.NumSetsLost)
+
+	matches, err := upcomingMatches(ctx, tournament, settings, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range matches {
+		b.WriteString("次の試合: " + matchLine(c, settings.location()) + "\n")
+	}
+	return ephemeral(b.String()), nil
+}
+
+// showMatches replies with the unplayed challenges of the current round for the team named, the caller's team
+// when no name is given, or every team when the name is "all".
+func showMatches(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, userID string, name string) (*slack.Msg, error) {
+	teamID := ""
+	switch name {
+	case "all":
+	case "":
+		_, id, denied, err := teamOfSlackUser(ctx, tournament, userID)
+		if err != nil {
+			return nil, err
+		}
+		if denied != nil {
+			denied.Text += "\n全チームの試合は `/ladder matches all` で表示できます。"
+			return denied, nil
+		}
+		teamID = id
+	default:
+		names, err := loadTeamNames(ctx, tournament)
+		if err != nil {
+			return nil, err
+		}
+		id, ok := findTeam(names, name)
+		if !ok {
+			return ephemeral(fmt.Sprintf("チーム %q が見つかりません。", name)), nil
+		}
+		teamID = id
+	}
+	matches, err := upcomingMatches(ctx, tournament, settings, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return ephemeral(fmt.Sprintf("Round %d の未消化の試合はありません。", settings.CurrentRound)), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Round %d の試合*\n", settings.CurrentRound)
+	for _, c := range matches {
+		b.WriteString(matchLine(c, settings.location()) + "\n")
+	}
+	return ephemeral(b.String()), nil
+}
+
+// upcomingMatches returns the unplayed challenges of the current round, scheduled ones first,
+// limited to one team when teamID is set.
+func upcomingMatches(ctx context.Context, tournament *firestore.DocumentRef, settings Tournament, teamID string) ([]Challenge, error) {
+	docs, err := tournament.Collection("challenges").Where("Round", "==", settings.CurrentRound).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Challenge, 0)
+	for _, doc := range docs {
+		var c Challenge
+		if err = doc.DataTo(&c); err != nil {
+			return nil, err
+		}
+		if c.Played() {
+			continue
+		}
+		if teamID != "" && c.ChallengerID != teamID && c.DefenderID != teamID {
+			continue
+		}
+		matches = append(matches, c)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Date.IsZero() != b.Date.IsZero() {
+			return !a.Date.IsZero()
+		}
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return a.Code < b.Code
+	})
+	return matches, nil
+}
+
+// matchLine formats a challenge with its match time, if scheduled.
+func matchLine(c Challenge, loc *time.Location) string {
+	date := "日程未定"
+	if !c.Date.IsZero() {
+		date = c.Date.In(loc).Format("2006-01-02 15:04")
+	}
+	return date + " " + render.Title(c.Match())
+}
@@ -0,0 +1,143 @@
+package announce
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+	"github.com/slack-go/slack"
+	"google.golang.org/api/iterator"
+)
+
+// player holds a player of the registry shared by all tournaments.
+type player struct {
+	ID          string `firestore:"-"`
+	Handle      string `firestore:"Handle"`
+	DisplayName string `firestore:"DisplayName"`
+	SlackUserID string `firestore:"SlackUserID"`
+}
+
+// name returns how the player is shown in announcements.
+func (p player) name() string {
+	if p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return p.Handle
+}
+
+// handleKey normalises a handle so that "@Topi" and "topi" refer to the same player.
+func handleKey(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// linkPlayer requests a link between the Slack user and the player with the given handle, e.g. "/ladder link @tappy".
+// The request is reported to organisers, who approve it with spladder-web; a new request replaces the previous one.
+// A player already linked to another Slack user can only be relinked by organisers.
+func linkPlayer(ctx context.Context, settings Tournament, cmd slack.SlashCommand, args []string) (*slack.Msg, error) {
+	if len(args) != 1 {
+		return ephemeral(commandUsage), nil
+	}
+	key := handleKey(args[0])
+	iter := firestoreClient().Collection("players").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var p player
+		if err = doc.DataTo(&p); err != nil {
+			return nil, err
+		}
+		if handleKey(p.Handle) != key {
+			continue
+		}
+		if p.SlackUserID == cmd.UserID {
+			return ephemeral(fmt.Sprintf("既に %s とリンクされています。", p.Handle)), nil
+		}
+		if p.SlackUserID != "" {
+			return ephemeral(fmt.Sprintf("%s は別の Slack ユーザーとリンクされています。運営に連絡してください。", p.Handle)), nil
+		}
+		linked, err := playerForSlackUser(ctx, cmd.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if linked != nil {
+			return ephemeral(fmt.Sprintf("既に %s とリンクされています。運営に連絡してください。", linked.Handle)), nil
+		}
+		_, err = firestoreClient().Collection("linkRequests").Doc(cmd.UserID).Set(ctx, ladder.LinkRequest{
+			PlayerID:      doc.Ref.ID,
+			Handle:        p.Handle,
+			SlackUserID:   cmd.UserID,
+			SlackUserName: cmd.UserName,
+			RequestedAt:   time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		message := fmt.Sprintf("<@%s> が %s とのリンクを申請しました。spladder-web で承認してください。", cmd.UserID, p.Handle)
+		log.Print(message)
+		if err = postToSlack(settings.organiserConfig(), render.Text(message)); err != nil {
+			log.Printf("Error notifying organisers of the link request from %s: %s", cmd.UserID, err)
+		}
+		return ephemeral(fmt.Sprintf("%s とのリンクを申請しました。運営の承認をお待ちください。", p.Handle)), nil
+	}
+	return ephemeral(fmt.Sprintf("プレイヤー %s は登録されていません。", args[0])), nil
+}
+
+// playerForSlackUser returns the player linked to a Slack user, or nil when there is none.
+func playerForSlackUser(ctx context.Context, userID string) (*player, error) {
+	docs, err := firestoreClient().Collection("players").Where("SlackUserID", "==", userID).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	var p player
+	if err = docs[0].DataTo(&p); err != nil {
+		return nil, err
+	}
+	p.ID = docs[0].Ref.ID
+	return &p, nil
+}
+
+// teamOfSlackUser returns the linked player of a Slack user and the ID of their team in the tournament.
+// It returns a reply explaining the problem when the user is not linked or plays for no team.
+func teamOfSlackUser(ctx context.Context, tournament *firestore.DocumentRef, userID string) (*player, string, *slack.Msg, error) {
+	p, err := playerForSlackUser(ctx, userID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if p == nil {
+		return nil, "", ephemeral("Slack ユーザーがプレイヤーとリンクされていません。`/ladder link <ハンドル>` でリンクを申請してください。"), nil
+	}
+	docs, err := tournament.Collection("teams").Where("playerIDs", "array-contains", p.ID).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(docs) == 0 {
+		return p, "", ephemeral(fmt.Sprintf("%s はこの大会のチームに登録されていません。", p.Handle)), nil
+	}
+	return p, docs[0].Ref.ID, nil, nil
+}
+
+// authorizeChallenge checks that a Slack user plays for one of the teams of a challenge,
+// and returns the player and the ID of their team.
+func authorizeChallenge(ctx context.Context, tournament *firestore.DocumentRef, userID string, key string, c Challenge) (*player, string, *slack.Msg, error) {
+	p, teamID, reply, err := teamOfSlackUser(ctx, tournament, userID)
+	if err != nil || reply != nil {
+		return p, teamID, reply, err
+	}
+	if teamID != c.ChallengerID && teamID != c.DefenderID {
+		return p, teamID, ephemeral(fmt.Sprintf("試合 %s の出場チームのメンバーではありません。", key)), nil
+	}
+	return p, teamID, nil, nil
+}
@@ -0,0 +1,140 @@
+package announce
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+)
+
+// reminder is a message sent a fixed time before a scheduled challenge.
+type reminder struct {
+	kind   string
+	before time.Duration
+}
+
+// reminders returns the reminders of the tournament, earliest first.
+func (t Tournament) reminders() []reminder {
+	hours := t.ReminderHours
+	if hours == 0 {
+		hours = 24
+	}
+	minutes := t.ReminderMinutes
+	if minutes == 0 {
+		minutes = 30
+	}
+	return []reminder{
+		{kind: "hours", before: time.Duration(hours) * time.Hour},
+		{kind: "minutes", before: time.Duration(minutes) * time.Minute},
+	}
+}
+
+// slackMentions returns mentions of the Slack users linked to the players of a team.
+func slackMentions(ctx context.Context, tournament *firestore.DocumentRef, teamID string) ([]string, error) {
+	team, err := tournament.Collection("teams").Doc(teamID).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids, _ := team.Data()["playerIDs"].([]interface{})
+	refs := make([]*firestore.DocumentRef, 0, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			refs = append(refs, firestoreClient().Collection("players").Doc(s))
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	docs, err := firestoreClient().GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	mentions := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var p player
+		if err = doc.DataTo(&p); err != nil {
+			return nil, err
+		}
+		if p.SlackUserID != "" {
+			mentions = append(mentions, "<@"+p.SlackUserID+">")
+		}
+	}
+	return mentions, nil
+}
+
+// SendMatchReminders reminds both teams of a scheduled challenge some hours and again some minutes before it starts,
+// mentioning their linked Slack users. It is meant to run every few minutes from Cloud Scheduler.
+// Each reminder is recorded as an announcement, so it is sent once per challenge and match time;
+// a reminder that fails to post is forgotten and sent by the next run, without holding up the others.
+func SendMatchReminders(ctx context.Context, m PubSubMessage) error {
+	tournament := tournamentRef()
+	settings, err := loadTournament(ctx, tournament)
+	if err != nil {
+		return err
+	}
+	loc := settings.location()
+	reminders := settings.reminders()
+	now := time.Now()
+
+	docs, err := tournament.Collection("challenges").Where("Round", "==", settings.CurrentRound).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		var c Challenge
+		if err = doc.DataTo(&c); err != nil {
+			log.Printf("Error decoding challenge %s: %s", doc.Ref.ID, err)
+			continue
+		}
+		if c.Date.IsZero() || !now.Before(c.Date) || c.Played() {
+			continue
+		}
+
+		// Only the latest reminder that is due is sent, so a late run does not send both at once.
+		var due *reminder
+		for i, r := range reminders {
+			if !now.Before(c.Date.Add(-r.before)) {
+				due = &reminders[i]
+			}
+		}
+		if due == nil {
+			continue
+		}
+		mentions := make([]string, 0)
+		for _, teamID := range []string{c.ChallengerID, c.DefenderID} {
+			teamMentions, err := slackMentions(ctx, tournament, teamID)
+			if err != nil {
+				log.Printf("Error reading players of %s: %s", teamID, err)
+				continue
+			}
+			mentions = append(mentions, teamMentions...)
+		}
+		message := fmt.Sprintf("%s\nあと%sで試合開始です！ %s %s\n", strings.Join(mentions, " "),
+			untilText(c.Date.Sub(now)), c.Date.In(loc).Format("2006-01-02 15:04"), render.Title(c.Match()))
+		// The key includes the match time, so a rescheduled challenge is reminded again.
+		key := fmt.Sprintf("%s-%s-reminder-%s-%d", tournament.ID, doc.Ref.ID, due.kind, c.Date.Unix())
+		if err = postAnnouncement(ctx, key, false, settings.slackConfig(), render.Text(message)); err != nil {
+			log.Printf("Error reminding %s: %s", doc.Ref.ID, err)
+		}
+	}
+	return nil
+}
+
+// untilText formats the time left before a match, rounded to minutes.
+func untilText(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d >= time.Hour {
+		if d%time.Hour == 0 {
+			return fmt.Sprintf("%d時間", d/time.Hour)
+		}
+		return fmt.Sprintf("%d時間%d分", d/time.Hour, (d%time.Hour)/time.Minute)
+	}
+	return fmt.Sprintf("%d分", d/time.Minute)
+}
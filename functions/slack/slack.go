@@ -0,0 +1,19 @@
+package announce
+
+import (
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/knagayama/ladder-firebase/ladder/render"
+)
+
+// slackConfig resolves the Slack destination of the tournament.
+// Secrets are never stored in Firestore: the tournament names the environment variables holding them,
+// which can be mapped from Secret Manager when deploying the function.
+func (t Tournament) slackConfig() ladder.SlackConfig {
+	return ladder.ResolveSlackConfig(t.SlackWebhookEnv, t.SlackTokenEnv, t.SlackChannel)
+}
+
+// postToSlack posts a message to the channel of cfg.
+func postToSlack(cfg ladder.SlackConfig, message render.Message) error {
+	_, err := cfg.Post(message.Text, message.Blocks, "")
+	return err
+}
@@ -0,0 +1,81 @@
+package announce
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/knagayama/ladder-firebase/ladder"
+)
+
+var (
+	youTubeID  = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	nicoID     = regexp.MustCompile(`^(lv|sm|so|nm)[0-9]+$`)
+	twitchName = regexp.MustCompile(`^[A-Za-z0-9_]{3,25}$`)
+	numericID  = regexp.MustCompile(`^[0-9]+$`)
+	// YouTube handles are 3 to 30 letters, digits, underscores, hyphens and periods.
+	youTubeHandle = regexp.MustCompile(`^@[A-Za-z0-9_.-]{3,30}$`)
+	twitterName   = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+	broadcastID   = regexp.MustCompile(`^[A-Za-z0-9]{1,32}$`)
+)
+
+// normalizeStreamURL checks that a stream URL points to a supported platform and returns the platform
+// and the canonical form of the URL, turning share links such as youtu.be/ID into the full form.
+func normalizeStreamURL(raw string) (string, string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("%q is not a URL", raw)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "youtube.com", "youtu.be":
+		id := ""
+		switch {
+		case host == "youtu.be" && len(path) == 1:
+			id = path[0]
+		case len(path) == 1 && path[0] == "watch":
+			id = u.Query().Get("v")
+		case len(path) == 2 && (path[0] == "live" || path[0] == "shorts"):
+			id = path[1]
+		case len(path) == 2 && youTubeHandle.MatchString(path[0]) && path[1] == "live":
+			return ladder.PlatformYouTube, "https://www.youtube.com/" + path[0] + "/live", nil
+		}
+		if youTubeID.MatchString(id) {
+			return ladder.PlatformYouTube, "https://www.youtube.com/watch?v=" + id, nil
+		}
+	case "twitch.tv":
+		if len(path) == 1 && twitchName.MatchString(path[0]) {
+			return ladder.PlatformTwitch, "https://www.twitch.tv/" + strings.ToLower(path[0]), nil
+		}
+		if len(path) == 2 && path[0] == "videos" && numericID.MatchString(path[1]) {
+			return ladder.PlatformTwitch, "https://www.twitch.tv/videos/" + path[1], nil
+		}
+	case "nicovideo.jp", "sp.nicovideo.jp", "live.nicovideo.jp", "live2.nicovideo.jp", "sp.live.nicovideo.jp", "nico.ms":
+		id := ""
+		if host == "nico.ms" && len(path) == 1 {
+			id = path[0]
+		} else if len(path) == 2 && path[0] == "watch" {
+			id = path[1]
+		}
+		if nicoID.MatchString(id) {
+			if strings.HasPrefix(id, "lv") {
+				return ladder.PlatformNiconico, "https://live.nicovideo.jp/watch/" + id, nil
+			}
+			return ladder.PlatformNiconico, "https://www.nicovideo.jp/watch/" + id, nil
+		}
+	case "twitter.com", "mobile.twitter.com", "x.com":
+		if len(path) == 3 && twitterName.MatchString(path[0]) && path[1] == "status" && numericID.MatchString(path[2]) {
+			return ladder.PlatformTwitter, "https://x.com/" + path[0] + "/status/" + path[2], nil
+		}
+		if len(path) == 3 && path[0] == "i" && path[1] == "broadcasts" && broadcastID.MatchString(path[2]) {
+			return ladder.PlatformTwitter, "https://x.com/i/broadcasts/" + path[2], nil
+		}
+	default:
+		return "", "", fmt.Errorf("%s is not a supported streaming site", u.Hostname())
+	}
+	return "", "", fmt.Errorf("%q is not a stream or video URL", raw)
+}
@@ -0,0 +1,57 @@
+package announce
+
+import (
+	"testing"
+
+	"github.com/knagayama/ladder-firebase/ladder"
+)
+
+func TestNormalizeStreamURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		platform string
+		url      string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", ladder.PlatformYouTube, "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{" https://youtu.be/dQw4w9WgXcQ ", ladder.PlatformYouTube, "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ&t=42", ladder.PlatformYouTube, "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ?si=abc", ladder.PlatformYouTube, "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://youtube.com/shorts/dQw4w9WgXcQ", ladder.PlatformYouTube, "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://www.youtube.com/@splathon.jp/live", ladder.PlatformYouTube, "https://www.youtube.com/@splathon.jp/live"},
+		{"https://www.twitch.tv/Hime_Ch", ladder.PlatformTwitch, "https://www.twitch.tv/hime_ch"},
+		{"http://twitch.tv/videos/1234567890", ladder.PlatformTwitch, "https://www.twitch.tv/videos/1234567890"},
+		{"https://live.nicovideo.jp/watch/lv123456789", ladder.PlatformNiconico, "https://live.nicovideo.jp/watch/lv123456789"},
+		{"https://sp.nicovideo.jp/watch/sm9", ladder.PlatformNiconico, "https://www.nicovideo.jp/watch/sm9"},
+		{"https://nico.ms/lv123456789", ladder.PlatformNiconico, "https://live.nicovideo.jp/watch/lv123456789"},
+		{"https://twitter.com/splathon/status/1525000000000000000", ladder.PlatformTwitter, "https://x.com/splathon/status/1525000000000000000"},
+		{"https://x.com/i/broadcasts/1YqKDoqLwXyJV", ladder.PlatformTwitter, "https://x.com/i/broadcasts/1YqKDoqLwXyJV"},
+
+		{"www.twitch.tv/hime", "", ""},
+		{"javascript:alert(1)", "", ""},
+		{"https://example.com/stream", "", ""},
+		{"https://www.youtube.com/watch?v=short", "", ""},
+		{"https://www.youtube.com/@a/live", "", ""},
+		{"https://www.youtube.com/channel/UC0123456789", "", ""},
+		{"https://www.twitch.tv/directory/game", "", ""},
+		{"https://www.twitch.tv/videos/latest", "", ""},
+		{"https://www.nicovideo.jp/watch/xx123", "", ""},
+		{"https://x.com/splathon", "", ""},
+		{"https://x.com/this_name_is_too_long/status/1", "", ""},
+	}
+	for _, tt := range tests {
+		platform, got, err := normalizeStreamURL(tt.raw)
+		if tt.url == "" {
+			if err == nil {
+				t.Errorf("normalizeStreamURL(%q) = %s, %s; want an error", tt.raw, platform, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("normalizeStreamURL(%q): %s", tt.raw, err)
+			continue
+		}
+		if platform != tt.platform || got != tt.url {
+			t.Errorf("normalizeStreamURL(%q) = %s, %s; want %s, %s", tt.raw, platform, got, tt.platform, tt.url)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package ladder
+
+import (
+	"strconv"
+	"time"
+)
+
+// Round is the number of a round of the tournament.
+type Round int
+
+func (r Round) String() string {
+	return strconv.Itoa(int(r))
+}
+
+// Challenge is a challenge document, as written by the spladder-web command and updated from Slack.
+type Challenge struct {
+	Round           Round    `firestore:"Round"`
+	Code            int      `firestore:"Code"`
+	Challenger      string   `firestore:"Challenger"`
+	ChallengerID    string   `firestore:"ChallengerID"`
+	ChallengerRank  int      `firestore:"ChallengerRank"`
+	ChallengerScore int      `firestore:"ChallengerScore"`
+	Defender        string   `firestore:"Defender"`
+	DefenderID      string   `firestore:"DefenderID"`
+	DefenderRank    int      `firestore:"DefenderRank"`
+	DefenderScore   int      `firestore:"DefenderScore"`
+	Division        Division `firestore:"Division"`
+	// ChallengerLineup and DefenderLineup hold the IDs of the players who played the challenge.
+	ChallengerLineup []string `firestore:"ChallengerLineup,omitempty"`
+	DefenderLineup   []string `firestore:"DefenderLineup,omitempty"`
+	// Date is the confirmed match time. ProposedDate is a time proposed by the team given by ProposedBy,
+	// or by the scheduler when ProposedBy is "scheduler".
+	Date         time.Time `firestore:"Date,omitempty"`
+	ProposedDate time.Time `firestore:"ProposedDate,omitempty"`
+	ProposedBy   string    `firestore:"ProposedBy,omitempty"`
+	// Forfeit records which side forfeited: "challenger", "defender" or "double".
+	// Flag is set by the deadline check on unscheduled or unplayed challenges.
+	Forfeit string `firestore:"Forfeit,omitempty"`
+	Flag    string `firestore:"Flag,omitempty"`
+	// SlackThreadTS is the Slack thread in which the teams of the division coordinate.
+	SlackThreadTS string `firestore:"SlackThreadTS,omitempty"`
+	// Streams lists every stream of the challenge. Streamer and StreamURL hold the single stream of older challenges.
+	Streams   []Stream `firestore:"Streams,omitempty"`
+	Streamer  string   `firestore:"Streamer,omitempty"`
+	StreamURL string   `firestore:"StreamURL,omitempty"`
+	// A score reported through Slack is held in ReportedChallengerScore and ReportedDefenderScore
+	// with ReportStatus ReportPending until the other team confirms it, or ReportDisputed if they reject it.
+	// It becomes ReportRecorded once an organiser records it with the lineups.
+	ReportStatus            string `firestore:"ReportStatus,omitempty"`
+	ReportedChallengerScore int    `firestore:"ReportedChallengerScore,omitempty"`
+	ReportedDefenderScore   int    `firestore:"ReportedDefenderScore,omitempty"`
+	// ReportedBy is the Slack user who reported the score with the slash command, ReportedTeam their team,
+	// and ReportedAt when. ConfirmedBy is the Slack user who confirmed it, or "organiser".
+	ReportedBy   string    `firestore:"ReportedBy,omitempty"`
+	ReportedTeam string    `firestore:"ReportedTeam,omitempty"`
+	ReportedAt   time.Time `firestore:"ReportedAt,omitempty"`
+	ConfirmedBy  string    `firestore:"ConfirmedBy,omitempty"`
+	ConfirmedAt  time.Time `firestore:"ConfirmedAt,omitempty"`
+}
+
+// AllStreams returns the streams of the challenge, including a stream recorded in StreamURL.
+func (c Challenge) AllStreams() []Stream {
+	streams := c.Streams
+	if c.StreamURL != "" && !c.HasStream(c.StreamURL) {
+		streams = append([]Stream{{Streamer: c.Streamer, URL: c.StreamURL}}, streams...)
+	}
+	return streams
+}
+
+// HasStream reports whether the challenge lists a stream with the given URL.
+func (c Challenge) HasStream(url string) bool {
+	for _, s := range c.Streams {
+		if s.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the challenge as it is presented to players.
+func (c Challenge) Match() Match {
+	return Match{
+		Round:          int(c.Round),
+		Code:           c.Code,
+		Division:       c.Division,
+		Challenger:     c.Challenger,
+		ChallengerRank: c.ChallengerRank,
+		Defender:       c.Defender,
+		DefenderRank:   c.DefenderRank,
+		Date:           c.Date,
+		Streams:        c.AllStreams(),
+	}
+}
+
+// Played reports whether a result has been recorded for the challenge.
+func (c Challenge) Played() bool {
+	return c.ChallengerScore > 0 || c.DefenderScore > 0 || c.Forfeit != ""
+}
+
+// Unrecorded reports whether the challenge holds a score reported through Slack that an organiser has not recorded.
+// Lineups cannot be given through Slack, so such scores do not count until an organiser enters them.
+func (c Challenge) Unrecorded() bool {
+	return c.ReportStatus != "" && c.ReportStatus != ReportRecorded
+}
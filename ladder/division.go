@@ -0,0 +1,86 @@
+// Package ladder holds the definitions shared by the spladder-web command and the Slack functions,
+// so that both read and present the data in Firestore the same way.
+package ladder
+
+// Division represents a division in the tournament. Challenges store it as an integer.
+type Division int
+
+// This iota represents a list of divisions.
+const (
+	X Division = iota
+	SPlusUpper
+	SPlusLower
+	SUpper
+	SLower
+	APlusUpper
+	APlusLower
+	AUpper
+	ALower
+	AMinusUpper
+	AMinusLower
+	BPlusUpper
+	BPlusLower
+	BUpper
+	BLower
+	BMinusUpper
+	BMinusLower
+	CPlusUpper
+	CPlusLower
+	CUpper
+	CLower
+	CMinusUpper
+	CMinusLower
+)
+
+func (div Division) String() string {
+	switch div {
+	case X:
+		return "X"
+	case SPlusUpper:
+		return "S+ Upper"
+	case SPlusLower:
+		return "S+ Lower"
+	case SUpper:
+		return "S Upper"
+	case SLower:
+		return "S Lower"
+	case APlusUpper:
+		return "A+ Upper"
+	case APlusLower:
+		return "A+ Lower"
+	case AUpper:
+		return "A Upper"
+	case ALower:
+		return "A Lower"
+	case AMinusUpper:
+		return "A- Upper"
+	case AMinusLower:
+		return "A- Lower"
+	case BPlusUpper:
+		return "B+ Upper"
+	case BPlusLower:
+		return "B+ Lower"
+	case BUpper:
+		return "B Upper"
+	case BLower:
+		return "B Lower"
+	case BMinusUpper:
+		return "B- Upper"
+	case BMinusLower:
+		return "B- Lower"
+	case CPlusUpper:
+		return "C+ Upper"
+	case CPlusLower:
+		return "C+ Lower"
+	case CUpper:
+		return "C Upper"
+	case CLower:
+		return "C Lower"
+	case CMinusUpper:
+		return "C- Upper"
+	case CMinusLower:
+		return "C- Lower"
+	default:
+		return "Unknown"
+	}
+}
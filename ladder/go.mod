@@ -0,0 +1,7 @@
+module github.com/knagayama/ladder-firebase/ladder
+
+go 1.19
+
+require github.com/slack-go/slack v0.12.3
+
+require github.com/gorilla/websocket v1.4.2 // indirect
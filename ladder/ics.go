@@ -0,0 +1,78 @@
+package ladder
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const icsTimeLayout = "20060102T150405Z"
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// icsLine writes a content line, folding it at 75 octets without splitting characters.
+func icsLine(w io.Writer, line string) error {
+	// Continuation lines start with a space, which counts towards the limit.
+	for limit := 75; len(line) > limit; limit = 74 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, err := io.WriteString(w, line[:cut]+"\r\n "); err != nil {
+			return err
+		}
+		line = line[cut:]
+	}
+	_, err := io.WriteString(w, line+"\r\n")
+	return err
+}
+
+// Event is a match in a calendar feed. ID is the document ID of its challenge.
+type Event struct {
+	ID    string
+	Match Match
+}
+
+// WriteCalendar writes the scheduled matches among events as an iCalendar feed named name.
+// Event UIDs are made of the tournament ID and the challenge ID, so they stay the same across feeds.
+func WriteCalendar(w io.Writer, tournament string, name string, events []Event, duration time.Duration, loc *time.Location) error {
+	scheduled := make([]Event, 0, len(events))
+	for _, e := range events {
+		if !e.Match.Date.IsZero() {
+			scheduled = append(scheduled, e)
+		}
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		return scheduled[i].Match.Date.Before(scheduled[j].Match.Date)
+	})
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//ladder-firebase//ladder//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:" + icsEscaper.Replace(name),
+		"X-WR-TIMEZONE:" + loc.String(),
+	}
+	stamp := time.Now().UTC().Format(icsTimeLayout)
+	for _, e := range scheduled {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+icsEscaper.Replace(tournament+"-"+e.ID)+"@ladder-firebase",
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+e.Match.Date.UTC().Format(icsTimeLayout),
+			"DTEND:"+e.Match.Date.Add(duration).UTC().Format(icsTimeLayout),
+			"SUMMARY:"+icsEscaper.Replace(e.Match.Summary()),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for _, line := range lines {
+		if err := icsLine(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package ladder
+
+import "time"
+
+// LinkRequest is a request by a Slack user to be linked to a registered player, stored in linkRequests/{SlackUserID}.
+// Linking lets the user report scores and schedule matches for the player's team, so an organiser approves it first.
+type LinkRequest struct {
+	PlayerID      string    `firestore:"PlayerID"`
+	Handle        string    `firestore:"Handle"`
+	SlackUserID   string    `firestore:"SlackUserID"`
+	SlackUserName string    `firestore:"SlackUserName"`
+	RequestedAt   time.Time `firestore:"RequestedAt"`
+}
@@ -0,0 +1,27 @@
+package ladder
+
+import (
+	"fmt"
+	"time"
+)
+
+// Match is a challenge as it is presented to players, in the calendar feed and in Slack.
+type Match struct {
+	Round          int
+	Code           int
+	Division       Division
+	Challenger     string
+	ChallengerRank int
+	Defender       string
+	DefenderRank   int
+	// Date is the confirmed match time, or zero while the challenge is unscheduled.
+	Date time.Time
+	// Streams lists the streams of the match, in the order they were added.
+	Streams []Stream
+}
+
+// Summary formats the match as the title of a calendar event.
+func (m Match) Summary() string {
+	return fmt.Sprintf("Div %s [%d-%d] %s (%d位) vs %s (%d位)", m.Division.String(), m.Round, m.Code,
+		m.Challenger, m.ChallengerRank, m.Defender, m.DefenderRank)
+}
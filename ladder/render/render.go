@@ -0,0 +1,211 @@
+// Package render builds the Slack messages of the tournament: the daily digest of matches,
+// stream announcements and reported scores. Every message carries Block Kit blocks
+// along with the plain text shown in notifications and by clients that cannot render blocks.
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/slack-go/slack"
+)
+
+// Action IDs of the buttons on a reported score.
+const (
+	ConfirmScoreAction = "confirm_score"
+	DisputeScoreAction = "dispute_score"
+)
+
+// Message is a Block Kit message along with the plain text shown in notifications
+// and by clients that cannot render blocks.
+type Message struct {
+	Text   string
+	Blocks []slack.Block
+}
+
+// Text returns a message consisting of plain text only.
+func Text(text string) Message {
+	return Message{Text: text}
+}
+
+func markdown(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, text, false, false)
+}
+
+func plainText(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, text, true, false)
+}
+
+// linkButton returns a button opening url, or nil when url is empty.
+func linkButton(actionID, label, url string) *slack.ButtonBlockElement {
+	if url == "" {
+		return nil
+	}
+	button := slack.NewButtonBlockElement(actionID, url, plainText(label))
+	button.URL = url
+	return button
+}
+
+// linkButtons returns an action block holding the non-nil buttons, or nil when there are none.
+func linkButtons(blockID string, buttons ...*slack.ButtonBlockElement) slack.Block {
+	elements := make([]slack.BlockElement, 0, len(buttons))
+	for _, button := range buttons {
+		if button != nil {
+			elements = append(elements, button)
+		}
+	}
+	if len(elements) == 0 {
+		return nil
+	}
+	return slack.NewActionBlock(blockID, elements...)
+}
+
+// teamFields returns the fields listing both teams of a match with their ranks.
+func teamFields(m ladder.Match) []*slack.TextBlockObject {
+	return []*slack.TextBlockObject{
+		markdown(fmt.Sprintf("*%s*\n%d位", m.Challenger, m.ChallengerRank)),
+		markdown(fmt.Sprintf("*%s*\n%d位", m.Defender, m.DefenderRank)),
+	}
+}
+
+// Title formats a match as it appears in announcements.
+func Title(m ladder.Match) string {
+	return fmt.Sprintf("[%d-%d] Div %s: %s (%d位) vs %s (%d位)", m.Round, m.Code, m.Division.String(),
+		m.Challenger, m.ChallengerRank, m.Defender, m.DefenderRank)
+}
+
+// maxBlocks is the number of blocks Slack accepts in a message.
+const maxBlocks = 50
+
+// Matches renders the daily digest of matches: a section per division with a field per team,
+// followed by the overdue matches, whose time has passed without a result, and those yet to be scheduled,
+// and buttons to the stream of each match and to the standings page.
+// When a section per match would exceed the block limit of Slack, each division is collapsed into one section
+// listing its matches, with links to their streams.
+// Matches are listed in the given order. To notify the channel, the header must mention it as "<!channel>",
+// as "@channel" is left as plain text in blocks.
+func Matches(header string, matches, overdue, unscheduled []ladder.Match, loc *time.Location, standingsURL string) Message {
+	var text strings.Builder
+	text.WriteString(header + "\n")
+
+	divisions := make([]ladder.Division, 0)
+	byDivision := make(map[ladder.Division][]ladder.Match)
+	for _, m := range matches {
+		text.WriteString(m.Date.In(loc).Format("2006-01-02 15:04") + " " + Title(m) + "\n")
+		if _, ok := byDivision[m.Division]; !ok {
+			divisions = append(divisions, m.Division)
+		}
+		byDivision[m.Division] = append(byDivision[m.Division], m)
+	}
+
+	var rest []slack.Block
+	if len(overdue) > 0 {
+		lines := make([]string, 0, len(overdue))
+		for _, m := range overdue {
+			lines = append(lines, m.Date.In(loc).Format("01/02 15:04")+" "+Title(m))
+		}
+		text.WriteString("\n日時を過ぎた未消化の試合\n" + strings.Join(lines, "\n") + "\n")
+		rest = append(rest, slack.NewDividerBlock(),
+			slack.NewSectionBlock(markdown("*日時を過ぎた未消化の試合*\n"+strings.Join(lines, "\n")), nil, nil))
+	}
+
+	if len(unscheduled) > 0 {
+		lines := make([]string, 0, len(unscheduled))
+		for _, m := range unscheduled {
+			lines = append(lines, Title(m))
+		}
+		text.WriteString("\n日程未定の試合\n" + strings.Join(lines, "\n") + "\n")
+		rest = append(rest, slack.NewDividerBlock(),
+			slack.NewSectionBlock(markdown("*日程未定の試合*\n"+strings.Join(lines, "\n")), nil, nil))
+	}
+
+	if actions := linkButtons("links", linkButton("standings", "順位表", standingsURL)); actions != nil {
+		rest = append(rest, slack.NewDividerBlock(), actions)
+	}
+
+	blocks := []slack.Block{slack.NewSectionBlock(markdown(header), nil, nil)}
+	if 1+2*len(divisions)+len(matches)+len(rest) <= maxBlocks {
+		for _, div := range divisions {
+			blocks = append(blocks, slack.NewDividerBlock(),
+				slack.NewHeaderBlock(plainText("Div "+div.String())))
+			for _, m := range byDivision[div] {
+				summary := fmt.Sprintf("*%s* [%d-%d]", m.Date.In(loc).Format("01/02 15:04"), m.Round, m.Code)
+				var accessory *slack.Accessory
+				if len(m.Streams) > 0 {
+					button := linkButton(fmt.Sprintf("stream-%d-%d", m.Round, m.Code), "配信を見る", m.Streams[0].URL)
+					accessory = slack.NewAccessory(button)
+				}
+				blocks = append(blocks, slack.NewSectionBlock(markdown(summary), teamFields(m), accessory))
+			}
+		}
+	} else {
+		for _, div := range divisions {
+			lines := []string{"*Div " + div.String() + "*"}
+			for _, m := range byDivision[div] {
+				line := fmt.Sprintf("%s [%d-%d] %s (%d位) vs %s (%d位)", m.Date.In(loc).Format("01/02 15:04"), m.Round, m.Code,
+					m.Challenger, m.ChallengerRank, m.Defender, m.DefenderRank)
+				if len(m.Streams) > 0 {
+					line += fmt.Sprintf(" <%s|配信を見る>", m.Streams[0].URL)
+				}
+				lines = append(lines, line)
+			}
+			blocks = append(blocks, slack.NewSectionBlock(markdown(strings.Join(lines, "\n")), nil, nil))
+		}
+	}
+	return Message{Text: text.String(), Blocks: append(blocks, rest...)}
+}
+
+// Stream renders the announcement of a new stream of a match.
+func Stream(m ladder.Match, s ladder.Stream, standingsURL string) Message {
+	kind := "配信"
+	if s.VOD {
+		kind = "アーカイブ"
+	}
+	if label := ladder.PlatformLabel(s.Platform); label != "" {
+		kind = label + " の" + kind
+	}
+	text := fmt.Sprintf("[配信URL] %s さんによる [%d-%d] %s (%d位) vs %s (%d位) の%sがあがったぞ！クリッククリックぅ→ %s\n",
+		s.Streamer, m.Round, m.Code, m.Challenger, m.ChallengerRank, m.Defender, m.DefenderRank, kind, s.URL)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(markdown(fmt.Sprintf("*%s* さんによる%sがあがったぞ！", s.Streamer, kind)), nil, nil),
+		slack.NewSectionBlock(markdown(fmt.Sprintf("*[%d-%d] Div %s*", m.Round, m.Code, m.Division.String())),
+			teamFields(m), nil),
+	}
+	if actions := linkButtons(fmt.Sprintf("stream-%d-%d", m.Round, m.Code),
+		linkButton("stream", kind+"を見る", s.URL),
+		linkButton("standings", "順位表", standingsURL)); actions != nil {
+		blocks = append(blocks, actions)
+	}
+	return Message{Text: text, Blocks: blocks}
+}
+
+// Report is a score reported through Slack by ReportedBy, awaiting confirmation by the other team.
+type Report struct {
+	Match           ladder.Match
+	ChallengerScore int
+	DefenderScore   int
+	ReportedBy      string
+}
+
+// ScoreLine formats a reported score.
+func ScoreLine(r Report) string {
+	m := r.Match
+	return fmt.Sprintf("[%d-%d] Div %s: %s %d - %d %s", m.Round, m.Code, m.Division.String(),
+		m.Challenger, r.ChallengerScore, r.DefenderScore, m.Defender)
+}
+
+// ScoreReport renders a reported score with buttons for the other team to confirm or dispute it.
+// The buttons carry key, the document ID of the challenge.
+func ScoreReport(key string, r Report) Message {
+	text := fmt.Sprintf("<@%s> が結果を報告しました: %s\n相手チームの方は確認をお願いします。", r.ReportedBy, ScoreLine(r))
+	confirm := slack.NewButtonBlockElement(ConfirmScoreAction, key, plainText("確認"))
+	confirm.Style = slack.StylePrimary
+	dispute := slack.NewButtonBlockElement(DisputeScoreAction, key, plainText("異議あり"))
+	dispute.Style = slack.StyleDanger
+	return Message{Text: text, Blocks: []slack.Block{
+		slack.NewSectionBlock(markdown(text), nil, nil),
+		slack.NewActionBlock("score-"+key, confirm, dispute),
+	}}
+}
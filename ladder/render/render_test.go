@@ -0,0 +1,97 @@
+package render
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/knagayama/ladder-firebase/ladder"
+	"github.com/slack-go/slack"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var jst = time.FixedZone("JST", 9*60*60)
+
+// golden compares a message with testdata/name.json, holding both its blocks and its plain text.
+func golden(t *testing.T, name string, message Message) {
+	t.Helper()
+	got, err := json.MarshalIndent(struct {
+		Text   string        `json:"text"`
+		Blocks []slack.Block `json:"blocks"`
+	}{message.Text, message.Blocks}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err = os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s does not match %s; run go test -update to accept it\ngot:\n%s", name, path, got)
+	}
+}
+
+func match(code int, div ladder.Division, date time.Time) ladder.Match {
+	return ladder.Match{
+		Round:          3,
+		Code:           code,
+		Division:       div,
+		Challenger:     "イカ研究所",
+		ChallengerRank: 2*code + 1,
+		Defender:       "Team Octo",
+		DefenderRank:   2 * code,
+		Date:           date,
+	}
+}
+
+func TestMatches(t *testing.T) {
+	streamed := match(1, ladder.X, time.Date(2022, 5, 14, 21, 0, 0, 0, jst))
+	streamed.Streams = []ladder.Stream{{Streamer: "hime", Platform: ladder.PlatformTwitch, URL: "https://www.twitch.tv/hime"}}
+	matches := []ladder.Match{
+		streamed,
+		match(2, ladder.X, time.Date(2022, 5, 14, 22, 0, 0, 0, jst)),
+		match(3, ladder.SPlusUpper, time.Date(2022, 5, 14, 22, 30, 0, 0, jst)),
+	}
+	overdue := []ladder.Match{match(4, ladder.SPlusLower, time.Date(2022, 5, 12, 21, 0, 0, 0, jst))}
+	unscheduled := []ladder.Match{match(5, ladder.SUpper, time.Time{})}
+	golden(t, "digest", Matches("<!channel> 本日のお品書きはこちら！", matches, overdue, unscheduled, jst, "https://example.com/standings"))
+}
+
+func TestMatchesCollapsed(t *testing.T) {
+	divisions := []ladder.Division{ladder.X, ladder.SPlusUpper, ladder.SPlusLower, ladder.SUpper, ladder.SLower}
+	matches := make([]ladder.Match, 0)
+	for i := 0; i < 50; i++ {
+		m := match(i+1, divisions[i/10], time.Date(2022, 5, 14, 12+i%10, 0, 0, 0, jst))
+		if i%10 == 0 {
+			m.Streams = []ladder.Stream{{Streamer: "hime", Platform: ladder.PlatformTwitch, URL: "https://www.twitch.tv/hime"}}
+		}
+		matches = append(matches, m)
+	}
+	message := Matches("<!channel> 本日のお品書きはこちら！", matches, nil, nil, jst, "https://example.com/standings")
+	if len(message.Blocks) > maxBlocks {
+		t.Errorf("got %d blocks, Slack accepts at most %d", len(message.Blocks), maxBlocks)
+	}
+	golden(t, "digest_collapsed", message)
+}
+
+func TestStream(t *testing.T) {
+	s := ladder.Stream{Streamer: "hime", Platform: ladder.PlatformYouTube, URL: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", VOD: true}
+	golden(t, "stream", Stream(match(2, ladder.SUpper, time.Time{}), s, "https://example.com/standings"))
+}
+
+func TestScoreReport(t *testing.T) {
+	r := Report{Match: match(5, ladder.AUpper, time.Time{}), ChallengerScore: 4, DefenderScore: 2, ReportedBy: "U012AB3CD"}
+	golden(t, "score_report", ScoreReport("3-5", r))
+}
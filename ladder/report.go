@@ -0,0 +1,14 @@
+package ladder
+
+// Report statuses of a score reported through Slack, stored in the ReportStatus field of a challenge.
+const (
+	// ReportPending is a score awaiting confirmation by the team that did not report it.
+	ReportPending = "pending"
+	// ReportConfirmed is a score confirmed by the other team. It does not count towards the ranking
+	// until an organiser has entered the lineups and recorded it.
+	ReportConfirmed = "confirmed"
+	// ReportDisputed is a score rejected by the other team, left for organisers to settle.
+	ReportDisputed = "disputed"
+	// ReportRecorded is a score recorded by an organiser along with the lineups of both teams.
+	ReportRecorded = "recorded"
+)
@@ -0,0 +1,70 @@
+package ladder
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/slack-go/slack"
+)
+
+// BotUsername is the name the ladder bot posts as.
+const BotUsername = "ladder_bot_for_fireba"
+
+// SlackConfig holds where a tournament posts to Slack.
+// A bot token and channel take precedence over a webhook URL. Only the bot can start threads.
+type SlackConfig struct {
+	WebhookURL string
+	Token      string
+	Channel    string
+}
+
+// ResolveSlackConfig resolves a Slack destination from the tournament settings.
+// Secrets are never stored in Firestore: the tournament names the environment variables holding them,
+// defaulting to SLACK_WEBHOOK_URL and SLACK_BOT_TOKEN. The channel defaults to SLACK_CHANNEL.
+func ResolveSlackConfig(webhookEnv, tokenEnv, channel string) SlackConfig {
+	if webhookEnv == "" {
+		webhookEnv = "SLACK_WEBHOOK_URL"
+	}
+	if tokenEnv == "" {
+		tokenEnv = "SLACK_BOT_TOKEN"
+	}
+	if channel == "" {
+		channel = os.Getenv("SLACK_CHANNEL")
+	}
+	return SlackConfig{
+		WebhookURL: os.Getenv(webhookEnv),
+		Token:      os.Getenv(tokenEnv),
+		Channel:    channel,
+	}
+}
+
+// CanThread reports whether messages can be posted in threads.
+func (c SlackConfig) CanThread() bool {
+	return c.Token != "" && c.Channel != ""
+}
+
+// Post posts a message with optional blocks, as a reply in the thread given by threadTS when it is set.
+// It returns the timestamp of the message, which is empty when posting through a webhook.
+func (c SlackConfig) Post(text string, blocks []slack.Block, threadTS string) (string, error) {
+	if c.CanThread() {
+		params := slack.NewPostMessageParameters()
+		params.Username = BotUsername
+		options := []slack.MsgOption{slack.MsgOptionText(text, false), slack.MsgOptionPostMessageParameters(params)}
+		if len(blocks) > 0 {
+			options = append(options, slack.MsgOptionBlocks(blocks...))
+		}
+		if threadTS != "" {
+			options = append(options, slack.MsgOptionTS(threadTS))
+		}
+		_, ts, err := slack.New(c.Token).PostMessage(c.Channel, options...)
+		return ts, err
+	}
+	if c.WebhookURL == "" {
+		return "", fmt.Errorf("no Slack webhook URL or bot token and channel configured")
+	}
+	msg := slack.WebhookMessage{Text: text, Username: BotUsername}
+	if len(blocks) > 0 {
+		msg.Blocks = &slack.Blocks{BlockSet: blocks}
+	}
+	return "", slack.PostWebhook(c.WebhookURL, &msg)
+}
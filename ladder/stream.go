@@ -0,0 +1,33 @@
+package ladder
+
+import "time"
+
+// Stream is a stream of a challenge from one player's perspective, live or as a VOD.
+type Stream struct {
+	Streamer string    `firestore:"Streamer"`
+	Platform string    `firestore:"Platform"`
+	URL      string    `firestore:"URL"`
+	VOD      bool      `firestore:"VOD"`
+	AddedAt  time.Time `firestore:"AddedAt"`
+}
+
+// Platforms streams may be hosted on.
+const (
+	PlatformYouTube  = "youtube"
+	PlatformTwitch   = "twitch"
+	PlatformNiconico = "niconico"
+	PlatformTwitter  = "twitter"
+)
+
+// platformLabels are the names of the platforms shown in announcements.
+var platformLabels = map[string]string{
+	PlatformYouTube:  "YouTube",
+	PlatformTwitch:   "Twitch",
+	PlatformNiconico: "ニコニコ",
+	PlatformTwitter:  "X (Twitter)",
+}
+
+// PlatformLabel returns the name of a stream's platform, or an empty string when it is unknown.
+func PlatformLabel(platform string) string {
+	return platformLabels[platform]
+}
@@ -0,0 +1,21 @@
+package ladder
+
+import "time"
+
+// Window is the period in which the challenges of a round must be played, stored in rounds/{round}.
+// End is also the deadline for reporting results.
+type Window struct {
+	Start time.Time `firestore:"Start"`
+	End   time.Time `firestore:"End"`
+}
+
+// Contains reports whether t falls within the window, both ends included. A window without dates contains any time.
+func (w Window) Contains(t time.Time) bool {
+	if !w.Start.IsZero() && t.Before(w.Start) {
+		return false
+	}
+	if !w.End.IsZero() && t.After(w.End) {
+		return false
+	}
+	return true
+}